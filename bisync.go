@@ -0,0 +1,807 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexellis/ssync/pkg/ignore"
+	"github.com/alexellis/ssync/pkg/ssynclog"
+)
+
+// hashedFileLimit is the largest file size, in bytes, for which bisync will
+// compute a content hash when building a baseline. Larger files are compared
+// by size and modification time only, matching what rsync itself does.
+const hashedFileLimit = 1 << 20 // 1MiB
+
+// bisyncOptions carries the flags that only apply to -bisync mode.
+type bisyncOptions struct {
+	maxDelete       int
+	resync          bool
+	conflictResolve string
+}
+
+// pathRecord is a single entry in a bisync baseline: one file as it was last
+// seen on one side of the sync.
+type pathRecord struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Mode    uint32    `json:"mode"`
+	Hash    string    `json:"hash,omitempty"`
+}
+
+// baseline is a listing of every file under an endpoint, keyed by path
+// relative to the endpoint root.
+type baseline map[string]pathRecord
+
+// bisyncState is the baseline recorded for both sides of a sync, plus the
+// rsync paths it was computed for (kept so a stale state dir can be spotted
+// if source/destination are ever renamed in place).
+type bisyncState struct {
+	Source      string   `json:"source"`
+	Destination string   `json:"destination"`
+	LocalBase   baseline `json:"local"`
+	RemoteBase  baseline `json:"remote"`
+}
+
+// runBisync performs one round of two-way synchronisation between source
+// (always local) and destination (local or remote). It walks both sides,
+// diffs each against the baseline recorded on the previous run, classifies
+// every changed path as a push, a pull or a conflict, and then applies the
+// result with rsync before writing the new baseline back to disk.
+func runBisync(source, destination string, matcher *ignore.Matcher, compress, verbose, progress, dryRun bool, logger *ssynclog.Logger, opts bisyncOptions) error {
+	stateDir, err := bisyncStateDir(source, destination)
+	if err != nil {
+		return fmt.Errorf("unable to determine bisync state dir: %w", err)
+	}
+
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		return fmt.Errorf("unable to create bisync state dir %s: %w", stateDir, err)
+	}
+
+	statePath := filepath.Join(stateDir, "baseline.json")
+
+	var prev bisyncState
+	if !opts.resync {
+		prev, err = loadBisyncState(statePath)
+		if err != nil {
+			return fmt.Errorf("unable to load bisync baseline: %w", err)
+		}
+	}
+
+	localNow, err := scanLocal(source, matcher, logger)
+	if err != nil {
+		return fmt.Errorf("unable to scan %s: %w", source, err)
+	}
+
+	remoteNow, err := scanEndpoint(destination, matcher, logger)
+	if err != nil {
+		return fmt.Errorf("unable to scan %s: %w", destination, err)
+	}
+
+	if opts.resync {
+		logger.Infof("Resync: re-establishing baseline without applying deletes.")
+		if dryRun {
+			return nil
+		}
+		return saveBisyncState(statePath, bisyncState{
+			Source:      source,
+			Destination: destination,
+			LocalBase:   localNow,
+			RemoteBase:  remoteNow,
+		})
+	}
+
+	localDelta := diffBaseline(prev.LocalBase, localNow)
+	remoteDelta := diffBaseline(prev.RemoteBase, remoteNow)
+
+	for old, renamed := range localDelta.renamed {
+		logger.Infof("bisync: detected local rename %s -> %s (will sync as delete %s + transfer %s)", old, renamed, old, renamed)
+	}
+	for old, renamed := range remoteDelta.renamed {
+		logger.Infof("bisync: detected remote rename %s -> %s (will sync as delete %s + transfer %s)", old, renamed, old, renamed)
+	}
+
+	plan := classifyDelta(localDelta, remoteDelta)
+
+	if opts.maxDelete >= 0 {
+		if len(plan.pushDeletes) > opts.maxDelete {
+			return fmt.Errorf("refusing to sync: %d deletions on %s exceed -max-delete=%d (pass -resync if this is expected)", len(plan.pushDeletes), destination, opts.maxDelete)
+		}
+		if len(plan.pullDeletes) > opts.maxDelete {
+			return fmt.Errorf("refusing to sync: %d deletions on %s exceed -max-delete=%d (pass -resync if this is expected)", len(plan.pullDeletes), source, opts.maxDelete)
+		}
+	}
+
+	if dryRun {
+		logger.Infof("[dry-run] would push %d changed and %d deleted path(s) to %s", len(plan.push), len(plan.pushDeletes), destination)
+		logger.Infof("[dry-run] would pull %d changed and %d deleted path(s) from %s", len(plan.pull), len(plan.pullDeletes), destination)
+		for _, path := range plan.conflicts {
+			logger.Infof("[dry-run] would resolve conflict on %s using -conflict-resolve=%s", path, opts.conflictResolve)
+		}
+		return nil
+	}
+
+	for _, path := range plan.conflicts {
+		loseLocal, err := resolveConflict(source, destination, path, localNow[path], remoteNow[path], opts.conflictResolve, logger)
+		if err != nil {
+			return fmt.Errorf("unable to resolve conflict on %s: %w", path, err)
+		}
+
+		if loseLocal {
+			// The remote version won; pull it down to replace the local
+			// copy that was just renamed aside.
+			plan.pull = append(plan.pull, path)
+		} else {
+			// The local version won; push it to replace the destination
+			// copy that was just renamed aside.
+			plan.push = append(plan.push, path)
+		}
+	}
+
+	if len(plan.push) > 0 || len(plan.pushDeletes) > 0 {
+		logger.Infof("bisync: pushing %d changed and %d deleted path(s) to %s", len(plan.push), len(plan.pushDeletes), destination)
+		runRsyncPaths(source, destination, matcher, plan.push, plan.pushDeletes, compress, verbose, progress, logger)
+	}
+
+	if len(plan.pull) > 0 || len(plan.pullDeletes) > 0 {
+		logger.Infof("bisync: pulling %d changed and %d deleted path(s) from %s", len(plan.pull), len(plan.pullDeletes), destination)
+		runRsyncPaths(destination, source, matcher, plan.pull, plan.pullDeletes, compress, verbose, progress, logger)
+	}
+
+	// Re-scan after applying the plan so the baseline reflects what both
+	// sides actually ended up with, including renamed conflict losers.
+	localAfter, err := scanLocal(source, matcher, logger)
+	if err != nil {
+		return fmt.Errorf("unable to rescan %s: %w", source, err)
+	}
+
+	remoteAfter, err := scanEndpoint(destination, matcher, logger)
+	if err != nil {
+		return fmt.Errorf("unable to rescan %s: %w", destination, err)
+	}
+
+	return saveBisyncState(statePath, bisyncState{
+		Source:      source,
+		Destination: destination,
+		LocalBase:   localAfter,
+		RemoteBase:  remoteAfter,
+	})
+}
+
+// bisyncStateDir returns ~/.ssync/<hash-of-source>-<hash-of-dest>/, creating
+// a stable, filesystem-safe location to keep the baseline for this
+// source/destination pair.
+func bisyncStateDir(source, destination string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	sourceHash := shortHash(source)
+	destHash := shortHash(destination)
+
+	return filepath.Join(homeDir, ".ssync", fmt.Sprintf("%s-%s", sourceHash, destHash)), nil
+}
+
+func shortHash(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+func loadBisyncState(path string) (bisyncState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return bisyncState{LocalBase: baseline{}, RemoteBase: baseline{}}, nil
+		}
+		return bisyncState{}, err
+	}
+
+	var state bisyncState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return bisyncState{}, err
+	}
+
+	if state.LocalBase == nil {
+		state.LocalBase = baseline{}
+	}
+	if state.RemoteBase == nil {
+		state.RemoteBase = baseline{}
+	}
+
+	return state, nil
+}
+
+func saveBisyncState(path string, state bisyncState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o644)
+}
+
+// scanLocal walks a local directory tree and records size, mtime and mode
+// for every non-excluded file, hashing small files so near-simultaneous
+// edits on both sides can still be told apart from a no-op.
+func scanLocal(root string, matcher *ignore.Matcher, logger *ssynclog.Logger) (baseline, error) {
+	result := baseline{}
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		if relPath == "." {
+			return nil
+		}
+
+		if isExcluded(path, root, matcher, info.IsDir(), logger) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		if isConflictBackup(info.Name()) {
+			// Conflict-loser backups are a local safety copy, not a file
+			// either side is meant to sync. Leaving them in the baseline
+			// would make the next run see them as "new" and push/pull them
+			// to the other side, where they'd be picked up as "new" again
+			// on the run after that - multiplying across both endpoints.
+			return nil
+		}
+
+		record := pathRecord{
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Mode:    uint32(info.Mode().Perm()),
+		}
+
+		if info.Size() <= hashedFileLimit {
+			hash, err := hashFile(path)
+			if err != nil {
+				return err
+			}
+			record.Hash = hash
+		}
+
+		result[filepath.ToSlash(relPath)] = record
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// scanEndpoint dispatches to a local walk or a remote listing depending on
+// whether rsyncPath names a local directory or a host:path rsync target.
+func scanEndpoint(rsyncPath string, matcher *ignore.Matcher, logger *ssynclog.Logger) (baseline, error) {
+	host, remoteDir, isRemote := splitRemoteRsyncPath(rsyncPath)
+	if !isRemote {
+		return scanLocal(rsyncPath, matcher, logger)
+	}
+
+	return scanRemote(host, remoteDir, matcher)
+}
+
+// splitRemoteRsyncPath splits an rsync "host:path" target into its host and
+// remote path, reporting false when the path is local.
+func splitRemoteRsyncPath(rsyncPath string) (host, path string, isRemote bool) {
+	idx := strings.Index(rsyncPath, ":")
+	if idx <= 0 {
+		return "", "", false
+	}
+
+	// Guard against "C:\..." style absolute paths being mistaken for a host.
+	if idx == 1 {
+		return "", "", false
+	}
+
+	return rsyncPath[:idx], rsyncPath[idx+1:], true
+}
+
+// scanRemote lists a remote directory over ssh using find+stat. GNU find's
+// -printf is tried first since it does the listing in a single process; on
+// hosts where rsync is reachable but find is the BSD/macOS flavour (no
+// -printf), it falls back to a portable find -exec stat formulation.
+func scanRemote(host, remoteDir string, matcher *ignore.Matcher) (baseline, error) {
+	remoteDir = strings.TrimSuffix(remoteDir, "/")
+	if remoteDir == "" {
+		remoteDir = "~"
+	}
+
+	out, err := runRemoteListing(host, gnuFindScript(remoteDir))
+	if err != nil {
+		out, err = runRemoteListing(host, bsdFindScript(remoteDir))
+		if err != nil {
+			return nil, fmt.Errorf("ssh listing of %s:%s failed: %w", host, remoteDir, err)
+		}
+	}
+
+	return parseRemoteListing(out, remoteDir, matcher)
+}
+
+// gnuFindScript lists files with GNU find's -printf, which is the fast path
+// on Linux hosts: %P is already relative to remoteDir, %T@ is the mtime as a
+// Unix timestamp, and %m is the permission bits in octal.
+func gnuFindScript(remoteDir string) string {
+	return fmt.Sprintf(`find %s -type f -printf '%%P\t%%s\t%%T@\t%%m\n'`, shellQuote(remoteDir))
+}
+
+// bsdFindScript is the portable fallback for hosts whose find doesn't
+// support -printf (BSD, macOS). It shells out to stat per file instead; %N
+// reproduces the path find found it at (so it still needs remoteDir
+// stripped off in Go), %z is size, %m is the mtime as a Unix timestamp, and
+// %p is the full raw st_mode in octal, including the file-type bits that
+// GNU's %m omits - parseRemoteListing masks those off.
+func bsdFindScript(remoteDir string) string {
+	return fmt.Sprintf(`find %s -type f -exec stat -f '%%N\t%%z\t%%m\t%%p' {} \;`, shellQuote(remoteDir))
+}
+
+func runRemoteListing(host, script string) (string, error) {
+	cmd := exec.Command("ssh", host, script)
+	var out strings.Builder
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
+
+func parseRemoteListing(out, remoteDir string, matcher *ignore.Matcher) (baseline, error) {
+	result := baseline{}
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 4 {
+			continue
+		}
+
+		relPath := strings.TrimPrefix(fields[0], remoteDir+"/")
+
+		if isConflictBackup(filepath.Base(relPath)) {
+			continue
+		}
+
+		if matcher.Match(relPath, false) {
+			continue
+		}
+
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		epoch, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			continue
+		}
+
+		mode, err := strconv.ParseUint(fields[3], 8, 32)
+		if err != nil {
+			continue
+		}
+
+		result[relPath] = pathRecord{
+			Size:    size,
+			ModTime: time.Unix(int64(epoch), 0),
+			Mode:    uint32(mode) & 0o777,
+		}
+	}
+
+	return result, scanner.Err()
+}
+
+// conflictBackupMarker is the substring that distinguishes a
+// "<name>.conflict-<timestamp>" backup written by resolveConflict from an
+// ordinary file named "*.conflict-*" by its owner. Timestamps are formatted
+// as "20060102-150405", so this can't collide with a plain extension.
+const conflictBackupMarker = ".conflict-"
+
+// isConflictBackup reports whether name looks like a conflict-loser backup
+// written by resolveConflict, so baseline scans can exclude it and avoid
+// treating it as a file to sync.
+func isConflictBackup(name string) bool {
+	idx := strings.LastIndex(name, conflictBackupMarker)
+	if idx < 0 {
+		return false
+	}
+
+	suffix := name[idx+len(conflictBackupMarker):]
+	return len(suffix) == len("20060102-150405") && strings.Count(suffix, "-") == 1
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// delta describes how a baseline has diverged from a fresh scan.
+type delta struct {
+	new               []string
+	newer             []string
+	deleted           []string
+	renamed           map[string]string // old path -> new path
+	changedPermission []string
+}
+
+// diffBaseline compares a prior baseline against a fresh scan of the same
+// side, classifying each path as new, newer, deleted, renamed or having had
+// only its permissions changed.
+func diffBaseline(prev, now baseline) delta {
+	d := delta{renamed: map[string]string{}}
+
+	seen := map[string]bool{}
+
+	for path, rec := range now {
+		prevRec, existed := prev[path]
+		if !existed {
+			d.new = append(d.new, path)
+			continue
+		}
+
+		seen[path] = true
+
+		switch {
+		case rec.Hash != "" && prevRec.Hash != "" && rec.Hash != prevRec.Hash:
+			d.newer = append(d.newer, path)
+		case (rec.Hash == "" || prevRec.Hash == "") && (rec.Size != prevRec.Size || !rec.ModTime.Equal(prevRec.ModTime)):
+			d.newer = append(d.newer, path)
+		case rec.Mode != prevRec.Mode:
+			d.changedPermission = append(d.changedPermission, path)
+		}
+	}
+
+	for path, prevRec := range prev {
+		if seen[path] {
+			continue
+		}
+		if _, stillThere := now[path]; stillThere {
+			continue
+		}
+
+		// A rename is recorded for logging, but still falls through to
+		// d.deleted: the new name is already in d.new from the loop above,
+		// so treating the old name as a plain delete reproduces the rename
+		// on the other side as delete-old + push/pull-new rather than
+		// leaving the old name to linger forever under neither bucket.
+		if renamedTo := findRename(prevRec, prev, now, seen); renamedTo != "" {
+			d.renamed[path] = renamedTo
+		}
+
+		d.deleted = append(d.deleted, path)
+	}
+
+	sort.Strings(d.new)
+	sort.Strings(d.newer)
+	sort.Strings(d.deleted)
+	sort.Strings(d.changedPermission)
+
+	return d
+}
+
+// findRename looks for a "new" path with the same size and hash as a path
+// that disappeared from the baseline, which is as close as a plain listing
+// can get to detecting a rename rather than a delete+create.
+func findRename(missing pathRecord, prev, now baseline, seen map[string]bool) string {
+	if missing.Hash == "" {
+		return ""
+	}
+
+	for path, rec := range now {
+		if seen[path] {
+			continue
+		}
+		if _, existedBefore := prev[path]; existedBefore {
+			continue
+		}
+		if rec.Hash == missing.Hash && rec.Size == missing.Size {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// syncPlan is the result of reconciling both sides' deltas: which paths to
+// push, which to pull, and which need a conflict to be resolved first.
+type syncPlan struct {
+	push        []string
+	pull        []string
+	pushDeletes []string
+	pullDeletes []string
+	conflicts   []string
+}
+
+// classifyDelta merges the local and remote deltas into a plan: a path
+// touched on only one side is pushed or pulled; a path touched on both sides
+// is a conflict.
+func classifyDelta(local, remote delta) syncPlan {
+	localChanged := map[string]bool{}
+	for _, p := range append(append(append([]string{}, local.new...), local.newer...), local.changedPermission...) {
+		localChanged[p] = true
+	}
+
+	remoteChanged := map[string]bool{}
+	for _, p := range append(append(append([]string{}, remote.new...), remote.newer...), remote.changedPermission...) {
+		remoteChanged[p] = true
+	}
+
+	var plan syncPlan
+
+	for p := range localChanged {
+		if remoteChanged[p] {
+			plan.conflicts = append(plan.conflicts, p)
+		} else {
+			plan.push = append(plan.push, p)
+		}
+	}
+
+	for p := range remoteChanged {
+		if !localChanged[p] {
+			plan.pull = append(plan.pull, p)
+		}
+	}
+
+	remoteDeleted := map[string]bool{}
+	for _, p := range remote.deleted {
+		remoteDeleted[p] = true
+	}
+	localDeleted := map[string]bool{}
+	for _, p := range local.deleted {
+		localDeleted[p] = true
+	}
+	for _, p := range local.deleted {
+		if remoteDeleted[p] {
+			// Deleted on both sides already - nothing to do.
+			continue
+		}
+		if remoteChanged[p] {
+			// Deleted locally but edited remotely: pushing the delete would
+			// destroy the remote's newer version. The remoteChanged loop
+			// above already queued p for pull, so the remote edit wins and
+			// reappears locally instead of being wiped out.
+			continue
+		}
+		plan.pushDeletes = append(plan.pushDeletes, p)
+	}
+	for _, p := range remote.deleted {
+		if localDeleted[p] {
+			// Deleted on both sides already - nothing to do.
+			continue
+		}
+		if !localChanged[p] {
+			plan.pullDeletes = append(plan.pullDeletes, p)
+		}
+	}
+
+	sort.Strings(plan.push)
+	sort.Strings(plan.pull)
+	sort.Strings(plan.pushDeletes)
+	sort.Strings(plan.pullDeletes)
+	sort.Strings(plan.conflicts)
+
+	return plan
+}
+
+// resolveConflict renames the losing side's file to <name>.conflict-<timestamp>
+// and logs the pair, so a path touched on both sides is never silently
+// overwritten. It reports whether the local side lost, so the caller can
+// reconcile the winning copy onto the side that was just renamed aside -
+// otherwise the loser would keep the timestamped backup but never regain a
+// file under its canonical name.
+func resolveConflict(source, destination, path string, local, remote pathRecord, strategy string, logger *ssynclog.Logger) (bool, error) {
+	timestamp := time.Now().Format("20060102-150405")
+
+	loseLocal, err := conflictLoser(local, remote, strategy)
+	if err != nil {
+		return false, err
+	}
+
+	if loseLocal {
+		losingPath := filepath.Join(source, path)
+		renamed := fmt.Sprintf("%s.conflict-%s", losingPath, timestamp)
+		if err := os.Rename(losingPath, renamed); err != nil {
+			return false, err
+		}
+		logger.Infof("conflict: %s changed on both sides, keeping remote version; local copy saved as %s", path, renamed)
+		return true, nil
+	}
+
+	host, remoteDir, isRemote := splitRemoteRsyncPath(destination)
+	if !isRemote {
+		losingPath := filepath.Join(destination, path)
+		renamed := fmt.Sprintf("%s.conflict-%s", losingPath, timestamp)
+		if err := os.Rename(losingPath, renamed); err != nil {
+			return false, err
+		}
+		logger.Infof("conflict: %s changed on both sides, keeping local version; destination copy saved as %s", path, renamed)
+		return false, nil
+	}
+
+	remotePath := strings.TrimSuffix(remoteDir, "/") + "/" + path
+	renamed := fmt.Sprintf("%s.conflict-%s", remotePath, timestamp)
+	cmd := exec.Command("ssh", host, fmt.Sprintf("mv %s %s", shellQuote(remotePath), shellQuote(renamed)))
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return false, err
+	}
+	logger.Infof("conflict: %s changed on both sides, keeping local version; remote copy saved as %s", path, renamed)
+	return false, nil
+}
+
+// conflictLoser reports whether the local side should lose the conflict,
+// according to the chosen -conflict-resolve strategy.
+func conflictLoser(local, remote pathRecord, strategy string) (bool, error) {
+	switch strategy {
+	case "newer":
+		return local.ModTime.Before(remote.ModTime), nil
+	case "older":
+		return local.ModTime.After(remote.ModTime), nil
+	case "larger":
+		return local.Size < remote.Size, nil
+	case "path1":
+		return false, nil
+	case "path2":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown -conflict-resolve strategy %q", strategy)
+	}
+}
+
+// runRsyncPaths is a thin wrapper over the rsync args builder that restricts
+// the transfer to an explicit list of relative paths via --files-from,
+// rather than mirroring the whole tree. deletedPaths are paths that no
+// longer exist on the source side; they're listed alongside changedPaths and
+// removed from the destination via --delete-missing-args, which tells rsync
+// to delete a destination entry instead of erroring when --files-from names
+// a path the sender doesn't have.
+func runRsyncPaths(source, destination string, matcher *ignore.Matcher, changedPaths, deletedPaths []string, compress, verbose, progress bool, logger *ssynclog.Logger) {
+	paths := append(append([]string{}, changedPaths...), deletedPaths...)
+	if len(paths) == 0 {
+		return
+	}
+
+	tmpFile, err := os.CreateTemp("", "ssync-bisync-files-*")
+	if err != nil {
+		logger.Errorf("unable to create --files-from list: %v", err)
+		return
+	}
+	defer os.Remove(tmpFile.Name())
+
+	for _, p := range paths {
+		fmt.Fprintln(tmpFile, p)
+	}
+	tmpFile.Close()
+
+	rsyncArgs := []string{"-a"}
+	if verbose {
+		rsyncArgs[0] += "v"
+	}
+	if compress {
+		rsyncArgs[0] += "z"
+	}
+	if progress {
+		rsyncArgs = append(rsyncArgs, "--progress")
+	}
+
+	if len(deletedPaths) > 0 {
+		rsyncArgs = append(rsyncArgs, "--delete", "--delete-missing-args")
+	}
+
+	rsyncArgs = append(rsyncArgs, "--files-from", tmpFile.Name())
+	rsyncArgs = append(rsyncArgs, matcher.RsyncFilterArgs()...)
+
+	rsyncArgs = append(rsyncArgs, source+"/", destination)
+
+	cmd := exec.Command("rsync", rsyncArgs...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		logger.Errorf("rsync command failed: %v", err)
+		return
+	}
+
+	if len(deletedPaths) > 0 {
+		pruneEmptyDirs(destination, deletedPaths, logger)
+	}
+}
+
+// pruneEmptyDirs removes directories on destination that were left behind
+// empty after deletedPaths were deleted from it above. The baseline only
+// tracks files, so a whole directory disappearing locally surfaces here as
+// a batch of individual file deletes with no corresponding rmdir - this
+// walks each deleted path's ancestors and removes the ones that are now
+// empty, on whichever side (local or remote) destination names.
+func pruneEmptyDirs(destination string, deletedPaths []string, logger *ssynclog.Logger) {
+	host, remoteDir, isRemote := splitRemoteRsyncPath(destination)
+	if !isRemote {
+		pruneLocalEmptyDirs(destination, deletedPaths)
+		return
+	}
+
+	pruneRemoteEmptyDirs(host, remoteDir, deletedPaths, logger)
+}
+
+// pruneLocalEmptyDirs walks up from each deleted path's parent directory,
+// removing directories under root while they're empty. os.Remove fails
+// (and the walk for that path stops) as soon as it hits a non-empty
+// directory, and the walk ends before it reaches root, so sibling files and
+// root are never touched.
+func pruneLocalEmptyDirs(root string, deletedPaths []string) {
+	for _, p := range deletedPaths {
+		for dir := filepath.Dir(p); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+			if err := os.Remove(filepath.Join(root, dir)); err != nil {
+				break
+			}
+		}
+	}
+}
+
+// pruneRemoteEmptyDirs mirrors pruneLocalEmptyDirs over ssh, using rmdir -p
+// from inside remoteDir so each deleted path's ancestor chain is removed in
+// one remote command without climbing above the remote root;
+// --ignore-fail-on-non-empty makes rmdir stop silently once it reaches a
+// directory that still has other content.
+func pruneRemoteEmptyDirs(host, remoteDir string, deletedPaths []string, logger *ssynclog.Logger) {
+	remoteDir = strings.TrimSuffix(remoteDir, "/")
+	dirs := make([]string, 0, len(deletedPaths))
+	for _, p := range deletedPaths {
+		dir := filepath.Dir(p)
+		if dir == "." {
+			continue
+		}
+		dirs = append(dirs, shellQuote(dir))
+	}
+	if len(dirs) == 0 {
+		return
+	}
+
+	cmd := exec.Command("ssh", host, "cd "+shellQuote(remoteDir)+" && rmdir -p --ignore-fail-on-non-empty "+strings.Join(dirs, " "))
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		logger.Errorf("unable to prune empty remote directories: %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiffBaseline(t *testing.T) {
+	now := time.Now()
+
+	prev := baseline{
+		"unchanged.txt": {Size: 10, ModTime: now, Hash: "aaa"},
+		"edited.txt":    {Size: 10, ModTime: now, Hash: "bbb"},
+		"chmodded.txt":  {Size: 10, ModTime: now, Mode: 0o644, Hash: "ccc"},
+		"removed.txt":   {Size: 10, ModTime: now, Hash: "ddd"},
+		"old-name.txt":  {Size: 20, ModTime: now, Hash: "eee"},
+	}
+
+	now2 := now.Add(time.Minute)
+	current := baseline{
+		"unchanged.txt": {Size: 10, ModTime: now, Hash: "aaa"},
+		"edited.txt":    {Size: 11, ModTime: now2, Hash: "fff"},
+		"chmodded.txt":  {Size: 10, ModTime: now, Mode: 0o755, Hash: "ccc"},
+		"added.txt":     {Size: 5, ModTime: now2, Hash: "ggg"},
+		"new-name.txt":  {Size: 20, ModTime: now, Hash: "eee"},
+	}
+
+	d := diffBaseline(prev, current)
+
+	if got, want := d.new, []string{"added.txt", "new-name.txt"}; !equalStrings(got, want) {
+		t.Errorf("new = %v, want %v", got, want)
+	}
+	if got, want := d.newer, []string{"edited.txt"}; !equalStrings(got, want) {
+		t.Errorf("newer = %v, want %v", got, want)
+	}
+	if got, want := d.changedPermission, []string{"chmodded.txt"}; !equalStrings(got, want) {
+		t.Errorf("changedPermission = %v, want %v", got, want)
+	}
+	if got, want := d.deleted, []string{"old-name.txt", "removed.txt"}; !equalStrings(got, want) {
+		t.Errorf("deleted = %v, want %v", got, want)
+	}
+	if renamedTo := d.renamed["old-name.txt"]; renamedTo != "new-name.txt" {
+		t.Errorf("renamed[old-name.txt] = %q, want new-name.txt", renamedTo)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestClassifyDelta(t *testing.T) {
+	local := delta{
+		new:     []string{"local-new.txt"},
+		deleted: []string{"local-deleted.txt"},
+	}
+	remote := delta{
+		newer:   []string{"remote-newer.txt"},
+		deleted: []string{"remote-deleted.txt"},
+	}
+
+	plan := classifyDelta(local, remote)
+
+	if got, want := plan.push, []string{"local-new.txt"}; !equalStrings(got, want) {
+		t.Errorf("push = %v, want %v", got, want)
+	}
+	if got, want := plan.pull, []string{"remote-newer.txt"}; !equalStrings(got, want) {
+		t.Errorf("pull = %v, want %v", got, want)
+	}
+	if got, want := plan.pushDeletes, []string{"local-deleted.txt"}; !equalStrings(got, want) {
+		t.Errorf("pushDeletes = %v, want %v (a path deleted locally must be deleted on the destination)", got, want)
+	}
+	if got, want := plan.pullDeletes, []string{"remote-deleted.txt"}; !equalStrings(got, want) {
+		t.Errorf("pullDeletes = %v, want %v (a path deleted remotely must be deleted locally)", got, want)
+	}
+}
+
+func TestClassifyDeltaConflict(t *testing.T) {
+	local := delta{new: []string{"both.txt"}}
+	remote := delta{newer: []string{"both.txt"}}
+
+	plan := classifyDelta(local, remote)
+
+	if got, want := plan.conflicts, []string{"both.txt"}; !equalStrings(got, want) {
+		t.Errorf("conflicts = %v, want %v", got, want)
+	}
+	if len(plan.push) != 0 || len(plan.pull) != 0 {
+		t.Errorf("a path changed on both sides should only appear in conflicts, got push=%v pull=%v", plan.push, plan.pull)
+	}
+}
+
+func TestClassifyDeltaDeleteVsEditConflict(t *testing.T) {
+	local := delta{deleted: []string{"both.txt"}}
+	remote := delta{newer: []string{"both.txt"}}
+
+	plan := classifyDelta(local, remote)
+
+	if got, want := plan.pull, []string{"both.txt"}; !equalStrings(got, want) {
+		t.Errorf("pull = %v, want %v (remote's edit must win over the local delete)", got, want)
+	}
+	if len(plan.pushDeletes) != 0 {
+		t.Errorf("pushDeletes = %v, want none: pushing this delete would destroy the remote's newer version", plan.pushDeletes)
+	}
+}
+
+func TestClassifyDeltaChangedPermission(t *testing.T) {
+	local := delta{changedPermission: []string{"local-chmod.txt"}}
+	remote := delta{changedPermission: []string{"remote-chmod.txt"}}
+
+	plan := classifyDelta(local, remote)
+
+	if got, want := plan.push, []string{"local-chmod.txt"}; !equalStrings(got, want) {
+		t.Errorf("push = %v, want %v (a permission-only change must still be synced)", got, want)
+	}
+	if got, want := plan.pull, []string{"remote-chmod.txt"}; !equalStrings(got, want) {
+		t.Errorf("pull = %v, want %v (a permission-only change must still be synced)", got, want)
+	}
+}
+
+func TestClassifyDeltaDeletedOnBothSides(t *testing.T) {
+	local := delta{deleted: []string{"gone.txt"}}
+	remote := delta{deleted: []string{"gone.txt"}}
+
+	plan := classifyDelta(local, remote)
+
+	if len(plan.pushDeletes) != 0 || len(plan.pullDeletes) != 0 {
+		t.Errorf("a path deleted on both sides needs no further action, got pushDeletes=%v pullDeletes=%v", plan.pushDeletes, plan.pullDeletes)
+	}
+}
+
+func TestConflictLoser(t *testing.T) {
+	older := pathRecord{ModTime: time.Unix(100, 0), Size: 10}
+	newer := pathRecord{ModTime: time.Unix(200, 0), Size: 20}
+
+	cases := []struct {
+		strategy      string
+		local, remote pathRecord
+		wantLoseLocal bool
+		wantErr       bool
+	}{
+		{strategy: "newer", local: older, remote: newer, wantLoseLocal: true},
+		{strategy: "newer", local: newer, remote: older, wantLoseLocal: false},
+		{strategy: "older", local: older, remote: newer, wantLoseLocal: false},
+		{strategy: "older", local: newer, remote: older, wantLoseLocal: true},
+		{strategy: "larger", local: older, remote: newer, wantLoseLocal: true},
+		{strategy: "path1", local: older, remote: newer, wantLoseLocal: false},
+		{strategy: "path2", local: older, remote: newer, wantLoseLocal: true},
+		{strategy: "bogus", local: older, remote: newer, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.strategy, func(t *testing.T) {
+			loseLocal, err := conflictLoser(tc.local, tc.remote, tc.strategy)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("conflictLoser(%q) = nil error, want one", tc.strategy)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("conflictLoser(%q): %v", tc.strategy, err)
+			}
+			if loseLocal != tc.wantLoseLocal {
+				t.Errorf("conflictLoser(%q) loseLocal = %v, want %v", tc.strategy, loseLocal, tc.wantLoseLocal)
+			}
+		})
+	}
+}
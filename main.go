@@ -1,24 +1,34 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"flag"
 	"fmt"
-	"log"
+	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+
+	"github.com/alexellis/ssync/pkg/ignore"
+	"github.com/alexellis/ssync/pkg/ssynclog"
+	"github.com/alexellis/ssync/pkg/transport"
 )
 
+// syncEndpoint describes one side of a sync. rsyncPath is the rsync-ready
+// form used by -bisync, which is tied to rsync specifically; localPath and
+// isLocal describe the local side; endpoint is the transport-agnostic view
+// used for the pluggable -transport sync path.
 type syncEndpoint struct {
 	name      string
 	rsyncPath string
 	isLocal   bool
 	localPath string
+	endpoint  transport.Endpoint
 }
 
 func main() {
@@ -29,6 +39,16 @@ func main() {
 	deleteVar := flag.Bool("delete", false, "Mirror destination by deleting extraneous files (default: false)")
 	progressVar := flag.Bool("progress", true, "Enable progress output (default: true)")
 	verboseVar := flag.Bool("verbose", true, "Enable verbose output (default: true)")
+	bisyncVar := flag.Bool("bisync", false, "Two-way sync between source and destination, tracking a baseline under ~/.ssync (default: false)")
+	maxDeleteVar := flag.Int("max-delete", 100, "Abort a -bisync run if either side would delete more than this many paths; 0 aborts on any delete, a negative value disables the check (default: 100)")
+	resyncVar := flag.Bool("resync", false, "Re-establish the -bisync baseline without applying any deletes (default: false)")
+	conflictResolveVar := flag.String("conflict-resolve", "newer", "How -bisync resolves a path changed on both sides: newer, older, larger, path1, path2 (default: newer)")
+	dryRunVar := flag.Bool("dry-run", false, "Show what would be synced without transferring anything (default: false)")
+	logLevelVar := flag.String("log-level", "info", "Minimum level to log: debug, info, warn, error (default: info)")
+	quietVar := flag.Bool("quiet", false, "Suppress console output (default: false)")
+	syslogVar := flag.Bool("syslog", false, "Mirror info-and-above log output to the local syslog daemon, Unix only (default: false)")
+	listenVar := flag.String("listen", "", "Address to serve /healthz, /status, /errors and /events on, e.g. :8080 (default: disabled)")
+	transportVar := flag.String("transport", "rsync", "Transport to use for syncing: rsync, sftp or rclone (default: rsync)")
 
 	compress := true
 	if compressVar != nil {
@@ -52,6 +72,20 @@ func main() {
 
 	flag.Parse()
 
+	logLevel, err := ssynclog.ParseLevel(*logLevelVar)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	logger := ssynclog.New(logLevel, *quietVar)
+	if *syslogVar {
+		if err := logger.EnableSyslog(); err != nil {
+			fmt.Printf("Error: Unable to enable -syslog: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	args := flag.Args()
 	if len(args) == 0 || len(args) > 2 {
 		fmt.Print(`ssync by Alex Ellis, Copyright 2025
@@ -68,6 +102,23 @@ Use "." to represent the current directory. Example flows:
 
 Use "--delete" to mirror the destination (removes files missing from the source)
 
+Use "-bisync" for two-way sync, tracking a baseline under ~/.ssync so that
+changes on either side are pushed/pulled and conflicting edits are never
+silently overwritten (see -resync, -max-delete, -conflict-resolve). Like
+every other mode, bisync honours -watch (default: true) and keeps
+re-running on local changes rather than exiting after one pass.
+
+Use "-dry-run" to see what would be synced without transferring anything,
+"-log-level" to control verbosity, "-quiet" to suppress console output, and
+"-syslog" to also send the log to the local syslog daemon
+
+Use "-listen=:8080" to serve /healthz, /status, /errors and /events over
+HTTP, useful when running ssync under tmux or systemd
+
+Use "-transport=sftp" or "-transport=rclone" to sync over something other
+than the rsync binary. A destination written as "sftp://user@host/path"
+or "rclone:<remote>:<path>" selects that transport automatically.
+
 To ignore large files i.e. binaries, create a .ssyncignore file
 
 [Push mode] The remote folder is created automatically if it doesn't exist
@@ -92,38 +143,38 @@ Learn more https://github.com/alexellis/ssync
 	// Get the current working directory
 	cwd, err := os.Getwd()
 	if err != nil {
-		fmt.Printf("Error: Unable to get current working directory: %v\n", err)
+		logger.Errorf("Unable to get current working directory: %v", err)
 		os.Exit(1)
 	}
 
 	// Get the user's home directory
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		fmt.Printf("Error: Unable to get user's home directory: %v\n", err)
+		logger.Errorf("Unable to get user's home directory: %v", err)
 		os.Exit(1)
 	}
 
 	// Compute relative path to the home directory
 	relativePath, err := filepath.Rel(homeDir, cwd)
 	if err != nil {
-		fmt.Printf("Error: Unable to compute relative path: %v\n", err)
+		logger.Errorf("Unable to compute relative path: %v", err)
 		os.Exit(1)
 	}
 
 	sourceEndpoint, err := newEndpoint(sourceArg, cwd, relativePath)
 	if err != nil {
-		fmt.Printf("Error: Unable to determine source: %v\n", err)
+		logger.Errorf("Unable to determine source: %v", err)
 		os.Exit(1)
 	}
 
 	destEndpoint, err := newEndpoint(destArg, cwd, relativePath)
 	if err != nil {
-		fmt.Printf("Error: Unable to determine destination: %v\n", err)
+		logger.Errorf("Unable to determine destination: %v", err)
 		os.Exit(1)
 	}
 
 	if !sourceEndpoint.isLocal && !destEndpoint.isLocal {
-		fmt.Println("Error: Either the source or destination must be the local machine.")
+		logger.Errorf("Either the source or destination must be the local machine.")
 		os.Exit(1)
 	}
 
@@ -135,39 +186,134 @@ Learn more https://github.com/alexellis/ssync
 		ignoreBase = destEndpoint.localPath
 	}
 
-	exclusions, err := loadIgnoreFile(ignoreBase)
+	matcher, err := ignore.Load(os.DirFS(ignoreBase), ".ssyncignore")
 	if err != nil {
-		fmt.Printf("Error: Unable to load .ssyncignore file: %v\n", err)
+		logger.Errorf("Unable to load .ssyncignore file: %v", err)
 		os.Exit(1)
 	}
 
-	// Perform an initial sync
-	fmt.Printf("ssync - Copyright Alex Ellis 2024\n\n%s\n=>\n%s\n\n", sourceEndpoint.name, destEndpoint.name)
+	logger.Infof("ssync - Copyright Alex Ellis 2024\n\n%s\n=>\n%s\n", sourceEndpoint.name, destEndpoint.name)
+
+	var status *Status
+	if *listenVar != "" {
+		status = NewStatus()
+		if err := startStatusServer(*listenVar, status, logger); err != nil {
+			logger.Errorf("Unable to start status server: %v", err)
+			os.Exit(1)
+		}
+	}
 
-	runRsync(sourceEndpoint.rsyncPath, destEndpoint.rsyncPath, exclusions, compress, verbose, progress, delete)
+	if *bisyncVar {
+		if !sourceEndpoint.isLocal {
+			logger.Errorf("-bisync requires the source to be the local machine.")
+			os.Exit(1)
+		}
+
+		opts := bisyncOptions{
+			maxDelete:       *maxDeleteVar,
+			resync:          *resyncVar,
+			conflictResolve: *conflictResolveVar,
+		}
+
+		if err := runBisync(sourceEndpoint.rsyncPath, destEndpoint.rsyncPath, matcher, compress, verbose, progress, *dryRunVar, logger, opts); err != nil {
+			logger.Errorf("bisync failed: %v", err)
+			os.Exit(1)
+		}
+
+		logger.Infof("Bisync completed successfully.")
+
+		if !*watch {
+			logger.Infof("Bisync complete. Watch mode disabled.")
+			return
+		}
+
+		logger.Infof("Watching %s for changes...", sourceEndpoint.localPath)
+
+		changeList := strings.Split(*changes, ",")
+		for i := 0; i < len(changeList); i++ {
+			changeList[i] = strings.ToUpper(strings.TrimSpace(changeList[i]))
+		}
+
+		// -resync only applies to the run that re-establishes the baseline
+		// above; every subsequent debounced run should diff normally.
+		watchOpts := opts
+		watchOpts.resync = false
+
+		startBisyncWatcher(sourceEndpoint.localPath, destEndpoint.rsyncPath, matcher, changeList, compress, verbose, progress, *dryRunVar, logger, watchOpts)
+		return
+	}
+
+	// The destination (or, for a pull, the source) may carry its own
+	// transport, e.g. from an "sftp://" or "rclone:" endpoint; that takes
+	// precedence over -transport so the URL form is self-describing.
+	transportName := *transportVar
+	if sourceEndpoint.endpoint.Transport != "" {
+		transportName = sourceEndpoint.endpoint.Transport
+	} else if destEndpoint.endpoint.Transport != "" {
+		transportName = destEndpoint.endpoint.Transport
+	}
+
+	// Warn up front rather than failing mid-sync if the selected transport
+	// can't honour a flag the user asked for.
+	if tr, err := transport.New(transportName); err == nil {
+		if delete && !tr.Capabilities().SupportsDelete {
+			logger.Warnf("-transport=%s does not support -delete; extraneous destination files will not be removed", transportName)
+		}
+	}
+
+	// Perform an initial sync
+	runSync(sourceEndpoint.endpoint, destEndpoint.endpoint, transportName, matcher, compress, verbose, progress, delete, *dryRunVar, logger, status)
 
 	// Check if we should watch for changes
 	if *watch {
 		if sourceEndpoint.isLocal {
-			fmt.Printf("\nWatching %s for changes...\n", sourceEndpoint.localPath)
+			logger.Infof("Watching %s for changes...", sourceEndpoint.localPath)
 
 			changeList := strings.Split(*changes, ",")
 			for i := 0; i < len(changeList); i++ {
 				changeList[i] = strings.ToUpper(strings.TrimSpace(changeList[i]))
 			}
 
-			startWatcher(sourceEndpoint.localPath, destEndpoint.rsyncPath, exclusions, changeList, compress, verbose, progress, delete)
+			startWatcher(sourceEndpoint.localPath, destEndpoint.endpoint, transportName, matcher, changeList, compress, verbose, progress, delete, *dryRunVar, logger, status)
 		} else {
-			fmt.Println("Watch mode is only available when syncing from the local machine. Skipping watcher.")
+			logger.Warnf("Watch mode is only available when syncing from the local machine. Skipping watcher.")
 		}
 	} else {
-		fmt.Println("Sync completed. Watch mode disabled.")
+		logger.Infof("Sync completed. Watch mode disabled.")
 	}
 }
 
+// newEndpoint classifies arg as a local path, an "sftp://user@host/path" or
+// "rclone:<remote>:<path>" URL-style endpoint, or a plain ssh-style host,
+// and fills in both the rsync-flavoured fields (used by -bisync) and the transport-agnostic endpoint field (used by the
+// pluggable -transport sync path).
 func newEndpoint(arg, cwd, relativePath string) (syncEndpoint, error) {
 	cleanCwd := filepath.Clean(cwd)
 
+	if strings.HasPrefix(arg, "rclone:") {
+		remotePath := strings.TrimPrefix(arg, "rclone:")
+		return syncEndpoint{
+			name:    arg,
+			isLocal: false,
+			endpoint: transport.Endpoint{
+				Transport: "rclone",
+				Path:      remotePath,
+			},
+		}, nil
+	}
+
+	if strings.HasPrefix(arg, "sftp://") {
+		ep, err := parseSFTPArg(arg)
+		if err != nil {
+			return syncEndpoint{}, err
+		}
+		return syncEndpoint{
+			name:     arg,
+			isLocal:  false,
+			endpoint: ep,
+		}, nil
+	}
+
 	if arg == "" || arg == "." || strings.HasPrefix(arg, "./") || strings.HasPrefix(arg, "../") || filepath.IsAbs(arg) {
 		localPath := cleanCwd
 		if arg != "" && arg != "." {
@@ -183,18 +329,78 @@ func newEndpoint(arg, cwd, relativePath string) (syncEndpoint, error) {
 			rsyncPath: localPath,
 			isLocal:   true,
 			localPath: localPath,
+			endpoint:  transport.Endpoint{IsLocal: true, Path: localPath},
 		}, nil
 	}
 
+	remoteDir := remoteHomePath(relativePath)
 	remotePath := formatRemotePath(arg, relativePath)
 
+	host := arg
+	user := ""
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		user = host[:at]
+		host = host[at+1:]
+	}
+
 	return syncEndpoint{
 		name:      remotePath,
 		rsyncPath: remotePath,
 		isLocal:   false,
+		endpoint: transport.Endpoint{
+			Host: host,
+			User: user,
+			Path: remoteDir,
+		},
 	}, nil
 }
 
+// parseSFTPArg parses an "sftp://[user@]host[:port]/path" endpoint.
+func parseSFTPArg(arg string) (transport.Endpoint, error) {
+	u, err := url.Parse(arg)
+	if err != nil {
+		return transport.Endpoint{}, fmt.Errorf("invalid sftp URL %q: %w", arg, err)
+	}
+
+	port := 0
+	if p := u.Port(); p != "" {
+		port, err = strconv.Atoi(p)
+		if err != nil {
+			return transport.Endpoint{}, fmt.Errorf("invalid sftp port in %q: %w", arg, err)
+		}
+	}
+
+	user := ""
+	if u.User != nil {
+		user = u.User.Username()
+	}
+
+	path := u.Path
+	if path == "" {
+		path = "."
+	}
+
+	return transport.Endpoint{
+		Transport: "sftp",
+		Host:      u.Hostname(),
+		User:      user,
+		Port:      port,
+		Path:      path,
+	}, nil
+}
+
+// remoteHomePath is the bare remote directory - "~" or "~/sub" - that a
+// sync into relativePath resolves to on the far side, without a host
+// prefix. It's used for the structured transport.Endpoint.Path; the
+// rsync-ready "host:~/sub" form is built by formatRemotePath instead.
+func remoteHomePath(relativePath string) string {
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(relativePath, "./"), "/")
+	if trimmed == "" || trimmed == "." {
+		return "~"
+	}
+	return fmt.Sprintf("~/%s", trimmed)
+}
+
 func formatRemotePath(host, relativePath string) string {
 	trimmed := relativePath
 	if trimmed == "" {
@@ -216,92 +422,149 @@ func formatRemotePath(host, relativePath string) string {
 	return fmt.Sprintf("%s:%s", host, remoteBase)
 }
 
-func loadIgnoreFile(dir string) ([]string, error) {
-	var exclusions []string
-	ignoreFilePath := filepath.Join(dir, ".ssyncignore")
-
-	file, err := os.Open(ignoreFilePath)
+// runSync performs one sync from src to dst using the named transport
+// (rsync, sftp or rclone), recording its outcome on status if -listen is in
+// effect. status may be nil.
+func runSync(src, dst transport.Endpoint, transportName string, matcher *ignore.Matcher, compress, verbose, progress, delete, dryRun bool, logger *ssynclog.Logger, status *Status) (transport.Result, error) {
+	tr, err := transport.New(transportName)
 	if err != nil {
-		// If the file doesn't exist, just return an empty list of exclusions
-		if os.IsNotExist(err) {
-			return exclusions, nil
-		}
-		return nil, err
+		logger.Errorf("%v", err)
+		return transport.Result{}, err
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+	opts := transport.SyncOptions{
+		Compress: compress,
+		Verbose:  verbose,
+		Progress: progress,
+		Delete:   delete,
+		DryRun:   dryRun,
+		Matcher:  matcher,
+	}
 
-		// Ignore empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
+	if status != nil {
+		status.RecordSyncStart()
+	}
+
+	result, syncErr := tr.Sync(context.Background(), src, dst, opts)
 
-		// Add the pattern directly (rsync interprets it correctly)
-		exclusions = append(exclusions, line)
+	if syncErr != nil {
+		logger.Errorf("sync failed: %v", syncErr)
+	} else if dryRun {
+		logger.Infof("[dry-run] sync would transfer %d file(s)", result.Stats.FilesTransferred)
+		if result.Command != "" {
+			logger.Infof("[dry-run] %s", result.Command)
+		}
+	} else {
+		logger.Infof("Sync completed successfully.")
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	if status != nil {
+		status.RecordSyncResult(result, syncErr)
 	}
 
-	return exclusions, nil
+	return result, syncErr
 }
-func runRsync(source, destination string, exclusions []string, compress, verbose, progress, delete bool) {
-	rsyncArgs := []string{
-		"-a", // Archive mode (recursive), verbose, compress
+func startWatcher(source string, dst transport.Endpoint, transportName string, matcher *ignore.Matcher, changeList []string, compress, verbose, progress, delete, dryRun bool, logger *ssynclog.Logger, status *Status) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Errorf("Unable to create file watcher: %v", err)
+		os.Exit(1)
 	}
+	defer watcher.Close()
 
-	if verbose {
-		rsyncArgs[0] += "v"
-	}
+	watched := &watchedDirs{dirs: map[string]struct{}{}}
 
-	if compress {
-		rsyncArgs[0] += "z"
+	// Walk the source tree up front so changes in subdirectories are seen
+	// directly, rather than only propagating on the next top-level event.
+	if err := addTreeToWatcher(watcher, watched, source, source, matcher, logger); err != nil {
+		logger.Errorf("Unable to watch directory: %v", err)
+		os.Exit(1)
 	}
 
-	if progress {
-		rsyncArgs = append(rsyncArgs, "--progress")
-	}
+	// Timer to debounce events
+	var syncTimer *time.Timer
+	const debounceDelay = 2 * time.Second
 
-	if delete {
-		rsyncArgs = append(rsyncArgs, "--delete")
-	}
+	go func() {
+		for {
+			select {
+			case event := <-watcher.Events:
 
-	// Add exclusions to the rsync arguments
-	for _, exclude := range exclusions {
-		rsyncArgs = append(rsyncArgs, "--exclude", exclude)
-	}
+				isDir := false
+				if info, err := os.Stat(event.Name); err == nil {
+					isDir = info.IsDir()
+				}
 
-	// Add source and destination
-	rsyncArgs = append(rsyncArgs, source+"/", destination)
+				if event.Op&fsnotify.Create != 0 && isDir {
+					if !isExcluded(event.Name, source, matcher, true, logger) {
+						if err := addTreeToWatcher(watcher, watched, source, event.Name, matcher, logger); err != nil {
+							logger.Warnf("watching new directory %s: %v", event.Name, err)
+						}
+					}
+				}
+
+				if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+					watched.removeTree(watcher, event.Name)
+				}
 
-	cmd := exec.Command("rsync", rsyncArgs...)
+				// Check if the event type matches
+				if !isWatchedEvent(event, changeList) {
+					continue
+				}
 
-	// Pipe stdout and stderr to the console
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+				// Check if the file path is excluded
+				if isExcluded(event.Name, source, matcher, isDir, logger) {
+					continue
+				}
 
-	// Run the rsync command
-	if err := cmd.Run(); err != nil {
-		fmt.Printf("Error: rsync command failed: %v\n", err)
-	} else {
-		fmt.Println("Sync completed successfully.")
-	}
+				name := strings.TrimPrefix(event.Name, source)
+
+				name = strings.TrimPrefix(name, "/")
+
+				logger.Debugf("[%s] %s", strings.ToLower(event.Op.String()), name)
+
+				// Handle debounce and trigger sync
+				if syncTimer != nil {
+					syncTimer.Stop()
+				}
+
+				if status != nil {
+					status.RecordPathChanged(name, time.Now().Add(debounceDelay))
+				}
+
+				syncTimer = time.AfterFunc(debounceDelay, func() {
+					src := transport.Endpoint{IsLocal: true, Path: source}
+					runSync(src, dst, transportName, matcher, compress, verbose, progress, delete, dryRun, logger, status)
+				})
+
+			case err := <-watcher.Errors:
+				logger.Errorf("watching files: %v", err)
+				if status != nil {
+					status.RecordError("", fmt.Sprintf("watching files: %v", err))
+				}
+			}
+		}
+	}()
+
+	// Keep the program running
+	select {}
 }
-func startWatcher(source, destination string, exclusions, changeList []string, compress, verbose, progress, delete bool) {
+
+// startBisyncWatcher re-runs runBisync on a debounce whenever source changes,
+// mirroring startWatcher's fsnotify-driven loop so that -bisync -watch keeps
+// tracking both sides instead of running once and exiting.
+func startBisyncWatcher(source, destination string, matcher *ignore.Matcher, changeList []string, compress, verbose, progress, dryRun bool, logger *ssynclog.Logger, opts bisyncOptions) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		fmt.Printf("Error: Unable to create file watcher: %v\n", err)
+		logger.Errorf("Unable to create file watcher: %v", err)
 		os.Exit(1)
 	}
 	defer watcher.Close()
 
-	// Watch source directory
-	if err := watcher.Add(source); err != nil {
-		fmt.Printf("Error: Unable to watch directory: %v\n", err)
+	watched := &watchedDirs{dirs: map[string]struct{}{}}
+
+	if err := addTreeToWatcher(watcher, watched, source, source, matcher, logger); err != nil {
+		logger.Errorf("Unable to watch directory: %v", err)
 		os.Exit(1)
 	}
 
@@ -314,33 +577,51 @@ func startWatcher(source, destination string, exclusions, changeList []string, c
 			select {
 			case event := <-watcher.Events:
 
+				isDir := false
+				if info, err := os.Stat(event.Name); err == nil {
+					isDir = info.IsDir()
+				}
+
+				if event.Op&fsnotify.Create != 0 && isDir {
+					if !isExcluded(event.Name, source, matcher, true, logger) {
+						if err := addTreeToWatcher(watcher, watched, source, event.Name, matcher, logger); err != nil {
+							logger.Warnf("watching new directory %s: %v", event.Name, err)
+						}
+					}
+				}
+
+				if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+					watched.removeTree(watcher, event.Name)
+				}
+
 				// Check if the event type matches
 				if !isWatchedEvent(event, changeList) {
 					continue
 				}
 
 				// Check if the file path is excluded
-				if isExcluded(event.Name, exclusions) {
+				if isExcluded(event.Name, source, matcher, isDir, logger) {
 					continue
 				}
 
 				name := strings.TrimPrefix(event.Name, source)
-
 				name = strings.TrimPrefix(name, "/")
 
-				fmt.Printf("[%s] %s\n", strings.ToLower(event.Op.String()), name)
+				logger.Debugf("[%s] %s", strings.ToLower(event.Op.String()), name)
 
-				// Handle debounce and trigger sync
+				// Handle debounce and trigger bisync
 				if syncTimer != nil {
 					syncTimer.Stop()
 				}
 
 				syncTimer = time.AfterFunc(debounceDelay, func() {
-					runRsync(source, destination, exclusions, compress, verbose, progress, delete)
+					if err := runBisync(source, destination, matcher, compress, verbose, progress, dryRun, logger, opts); err != nil {
+						logger.Errorf("bisync failed: %v", err)
+					}
 				})
 
 			case err := <-watcher.Errors:
-				fmt.Printf("Error watching files: %v\n", err)
+				logger.Errorf("watching files: %v", err)
 			}
 		}
 	}()
@@ -348,6 +629,82 @@ func startWatcher(source, destination string, exclusions, changeList []string, c
 	// Keep the program running
 	select {}
 }
+
+// watchedDirs tracks the set of directories currently registered with the
+// fsnotify watcher, so the event loop can add newly created directories and
+// prune ones that are removed or renamed away.
+type watchedDirs struct {
+	mu   sync.Mutex
+	dirs map[string]struct{}
+}
+
+func (w *watchedDirs) add(path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.dirs[path] = struct{}{}
+}
+
+func (w *watchedDirs) has(path string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, ok := w.dirs[path]
+	return ok
+}
+
+// removeTree stops watching path and every directory beneath it, in case an
+// entire subtree was removed or renamed away in one event.
+func (w *watchedDirs) removeTree(watcher *fsnotify.Watcher, path string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	prefix := path + string(os.PathSeparator)
+	for dir := range w.dirs {
+		if dir != path && !strings.HasPrefix(dir, prefix) {
+			continue
+		}
+		watcher.Remove(dir)
+		delete(w.dirs, dir)
+	}
+}
+
+// addTreeToWatcher walks root and adds every non-excluded directory to the
+// watcher, including root itself. source is the overall watched tree's
+// root, used to resolve ignore patterns, which may differ from root when a
+// newly created subdirectory is being added after startup. Files already
+// inside a newly added directory are visited by the walk but not reported
+// as changes; they are transferred by the next sync, whatever triggers it.
+func addTreeToWatcher(watcher *fsnotify.Watcher, watched *watchedDirs, source, root string, matcher *ignore.Matcher, logger *ssynclog.Logger) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// The directory may have disappeared between the event firing
+			// and us walking it - that's not fatal, just skip it.
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+
+		if !info.IsDir() {
+			return nil
+		}
+
+		if path != source && isExcluded(path, source, matcher, true, logger) {
+			return filepath.SkipDir
+		}
+
+		if watched.has(path) {
+			return nil
+		}
+
+		if err := watcher.Add(path); err != nil {
+			return err
+		}
+		watched.add(path)
+		logger.Debugf("watching %s", path)
+
+		return nil
+	})
+}
 func isWatchedEvent(event fsnotify.Event, changeList []string) bool {
 
 	for _, changeType := range changeList {
@@ -376,52 +733,18 @@ func isWatchedEvent(event fsnotify.Event, changeList []string) bool {
 	}
 	return false
 }
-func isExcluded(path string, exclusions []string) bool {
-	// Normalize the absolute path from fsnotify to a relative path
-	cwd, err := os.Getwd()
-	if err != nil {
-		fmt.Printf("Error: Unable to get current working directory: %v\n", err)
-		return false
-	}
 
-	relPath, err := filepath.Rel(cwd, path)
+// isExcluded reports whether path, which lives under source, is excluded by
+// matcher. isDir must reflect whether path itself names a directory, since
+// directory-only patterns only ever exclude directories. logger routes its
+// (rare) path-resolution error through the same -quiet/-log-level/-syslog
+// rules as every other status line.
+func isExcluded(path, source string, matcher *ignore.Matcher, isDir bool, logger *ssynclog.Logger) bool {
+	relPath, err := filepath.Rel(source, path)
 	if err != nil {
-		fmt.Printf("Error: Unable to make path relative: %v\n", err)
+		logger.Errorf("unable to make path relative: %v", err)
 		return false
 	}
 
-	log.Printf("relPath: %s, cwd: %s", relPath, cwd)
-
-	// Match the normalized path against exclusions
-	for _, pattern := range exclusions {
-		// Debug log for pattern matching
-
-		// Handle wildcard patterns like "*.swp"
-		if strings.Contains(pattern, "*") {
-			matched, err := filepath.Match(pattern, filepath.Base(relPath))
-			if err != nil {
-				fmt.Printf("Error: Invalid pattern %s\n", pattern)
-				continue
-			}
-			if matched {
-				return true
-			}
-		}
-
-		// Handle rooted patterns like "/secret"
-		if strings.HasPrefix(pattern, "/") {
-			trimmed := strings.TrimPrefix(pattern, "/")
-			if relPath == trimmed {
-				return true
-			}
-		}
-
-		// Handle general filename matches like "secret"
-		if filepath.Base(relPath) == pattern {
-			return true
-		}
-	}
-
-	// If no match, it's not excluded
-	return false
+	return matcher.Match(filepath.ToSlash(relPath), isDir)
 }
@@ -0,0 +1,184 @@
+// Package ignore parses .ssyncignore files into gitignore-style pathspec
+// rules and matches paths against them, so the file watcher and the rsync
+// argument builder can agree on exactly what is excluded.
+package ignore
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// rule is a single parsed line from a .ssyncignore file.
+type rule struct {
+	pattern  string // slash-separated, without a leading or trailing "/"
+	negate   bool   // leading "!"
+	dirOnly  bool   // trailing "/"
+	anchored bool   // rooted to the ignore file's directory
+}
+
+// Matcher evaluates a path against an ordered list of ignore rules, last
+// match wins, so that a later "!pattern" can re-include an earlier match.
+type Matcher struct {
+	rules []rule
+}
+
+// Load reads path from fsys and parses it into a Matcher. A missing file is
+// not an error: it yields an empty Matcher that excludes nothing.
+func Load(fsys fs.FS, path string) (*Matcher, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &Matcher{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var m Matcher
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		m.rules = append(m.rules, parseRule(trimmed))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+func parseRule(line string) rule {
+	r := rule{}
+
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	// A pattern containing a "/" anywhere but the end is anchored to the
+	// ignore file's directory, matching git's own rule; a bare filename
+	// pattern instead matches at any depth.
+	r.anchored = strings.Contains(line, "/")
+
+	line = strings.TrimPrefix(line, "/")
+	r.pattern = line
+
+	return r
+}
+
+// Match reports whether relPath (slash-separated, relative to the ignore
+// file's directory) is excluded by this matcher. isDir should reflect
+// whether relPath itself names a directory, since directory-only patterns
+// only ever exclude directories (and everything beneath them).
+func (m *Matcher) Match(relPath string, isDir bool) bool {
+	if m == nil || relPath == "" || relPath == "." {
+		return false
+	}
+
+	relPath = strings.Trim(relPath, "/")
+	segments := strings.Split(relPath, "/")
+
+	matched := false
+	for _, r := range m.rules {
+		if r.match(segments, isDir) {
+			matched = !r.negate
+		}
+	}
+
+	return matched
+}
+
+// match reports whether the rule applies to segments, either because the
+// full path matches, or because one of its ancestor directories matches the
+// pattern. A directory-only pattern matches the full path only when isDir
+// is set.
+func (r rule) match(segments []string, isDir bool) bool {
+	patSegs := strings.Split(r.pattern, "/")
+
+	starts := []int{0}
+	if !r.anchored {
+		starts = make([]int, len(segments))
+		for i := range segments {
+			starts[i] = i
+		}
+	}
+
+	for _, start := range starts {
+		sub := segments[start:]
+
+		if r.dirOnly {
+			for i := 1; i <= len(sub); i++ {
+				if !globMatch(patSegs, sub[:i]) {
+					continue
+				}
+				if i == len(sub) {
+					if isDir {
+						return true
+					}
+					continue
+				}
+				// sub[:i] names an ancestor directory of the path, so
+				// everything beneath it is excluded too.
+				return true
+			}
+			continue
+		}
+
+		// A match on an ancestor directory excludes everything beneath it,
+		// just as it does for a directory-only pattern above.
+		for i := 1; i <= len(sub); i++ {
+			if globMatch(patSegs, sub[:i]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// globMatch implements doublestar-style matching of a slash-split pattern
+// against a slash-split name: "**" matches zero or more whole path
+// segments, while any other segment is matched with filepath.Match
+// semantics (so "*", "?" and "[...]" work as expected within a segment).
+func globMatch(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+
+	if pattern[0] == "**" {
+		if globMatch(pattern[1:], name) {
+			return true
+		}
+		if len(name) > 0 && globMatch(pattern, name[1:]) {
+			return true
+		}
+		return false
+	}
+
+	if len(name) == 0 {
+		return false
+	}
+
+	matched, err := path.Match(pattern[0], name[0])
+	if err != nil || !matched {
+		return false
+	}
+
+	return globMatch(pattern[1:], name[1:])
+}
@@ -0,0 +1,125 @@
+package ignore
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func loadMatcher(t *testing.T, contents string) *Matcher {
+	t.Helper()
+
+	fsys := fstest.MapFS{
+		".ssyncignore": {Data: []byte(contents)},
+	}
+
+	m, err := Load(fsys, ".ssyncignore")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return m
+}
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		name     string
+		ignore   string
+		relPath  string
+		isDir    bool
+		excluded bool
+	}{
+		{
+			name:     "basename glob",
+			ignore:   "*.log",
+			relPath:  "debug.log",
+			excluded: true,
+		},
+		{
+			name:     "basename glob at depth",
+			ignore:   "*.log",
+			relPath:  "nested/debug.log",
+			excluded: true,
+		},
+		{
+			name:     "anchored pattern only matches at root",
+			ignore:   "/build",
+			relPath:  "nested/build",
+			excluded: false,
+		},
+		{
+			name:     "anchored pattern matches at root",
+			ignore:   "/build",
+			relPath:  "build",
+			isDir:    true,
+			excluded: true,
+		},
+		{
+			name:     "dir-only pattern excludes descendants",
+			ignore:   "node_modules/",
+			relPath:  "node_modules/some/file.js",
+			excluded: true,
+		},
+		{
+			name:     "dir-only pattern does not match a file of the same name",
+			ignore:   "node_modules/",
+			relPath:  "node_modules",
+			isDir:    false,
+			excluded: false,
+		},
+		{
+			name:     "recursive glob",
+			ignore:   "**/fixtures/*.json",
+			relPath:  "a/b/fixtures/data.json",
+			excluded: true,
+		},
+		{
+			name:     "later negation re-includes",
+			ignore:   "*.log\n!important.log",
+			relPath:  "important.log",
+			excluded: false,
+		},
+		{
+			name:     "negation does not affect unrelated paths",
+			ignore:   "*.log\n!important.log",
+			relPath:  "debug.log",
+			excluded: true,
+		},
+		{
+			name:     "last matching rule wins, not first",
+			ignore:   "!keep.txt\nkeep.txt",
+			relPath:  "keep.txt",
+			excluded: true,
+		},
+		{
+			name:     "comments and blank lines are ignored",
+			ignore:   "# comment\n\n*.tmp",
+			relPath:  "scratch.tmp",
+			excluded: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := loadMatcher(t, tc.ignore)
+			if got := m.Match(tc.relPath, tc.isDir); got != tc.excluded {
+				t.Errorf("Match(%q, isDir=%v) = %v, want %v", tc.relPath, tc.isDir, got, tc.excluded)
+			}
+		})
+	}
+}
+
+func TestMatchNilMatcher(t *testing.T) {
+	var m *Matcher
+	if m.Match("anything", false) {
+		t.Error("nil Matcher should exclude nothing")
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	m, err := Load(fstest.MapFS{}, ".ssyncignore")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if m.Match("anything", false) {
+		t.Error("missing .ssyncignore should exclude nothing")
+	}
+}
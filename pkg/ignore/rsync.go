@@ -0,0 +1,94 @@
+package ignore
+
+import "strings"
+
+// RsyncFilterArgs translates the matcher's rules into rsync --include /
+// --exclude arguments. rsync evaluates its filter list first-match-wins,
+// the opposite of the last-match-wins semantics Match uses for .ssyncignore,
+// so the rules are emitted in reverse order: the first rule rsync can match
+// is then the last rule that would have matched here, producing the same
+// outcome under rsync's own evaluation order.
+//
+// A negated pattern underneath a dirOnly exclude needs one more thing
+// rsync's own docs call out explicitly: excluding a directory makes rsync
+// prune it before it ever reaches a later --include for a path below it, so
+// re-including "node_modules/keep-me" after "node_modules/" is excluded
+// does nothing on its own. For every such negated pattern, also emit an
+// --include for each ancestor directory that Match would otherwise exclude,
+// so rsync is allowed to descend far enough to even consider the negation.
+// Each such ancestor also gets a trailing "--exclude /ancestor/*", placed
+// after every other rule, so the ancestor's other contents stay excluded.
+func (m *Matcher) RsyncFilterArgs() []string {
+	if m == nil {
+		return nil
+	}
+
+	var args []string
+	var ancestorExcludes []string
+	includedAncestors := map[string]bool{}
+
+	for i := len(m.rules) - 1; i >= 0; i-- {
+		r := m.rules[i]
+
+		if r.negate {
+			for _, ancestor := range ancestorDirs(r.pattern) {
+				if includedAncestors[ancestor] || !m.Match(ancestor, true) {
+					continue
+				}
+				includedAncestors[ancestor] = true
+				args = append(args, "--include", "/"+ancestor)
+				ancestorExcludes = append(ancestorExcludes, "--exclude", "/"+ancestor+"/*")
+			}
+		}
+
+		flag := "--exclude"
+		if r.negate {
+			flag = "--include"
+		}
+
+		pattern := r.pattern
+		if r.anchored {
+			pattern = "/" + pattern
+		}
+		if r.dirOnly {
+			pattern += "/"
+		}
+
+		args = append(args, flag, pattern)
+	}
+
+	return append(args, ancestorExcludes...)
+}
+
+// ancestorDirs returns every ancestor directory of pattern, shallowest
+// first, e.g. "node_modules/sub/keep-me" -> ["node_modules",
+// "node_modules/sub"]. A pattern with no "/" has no ancestor to permit
+// descent into, so it returns nil.
+func ancestorDirs(pattern string) []string {
+	segments := strings.Split(pattern, "/")
+	if len(segments) <= 1 {
+		return nil
+	}
+
+	dirs := make([]string, 0, len(segments)-1)
+	for i := 1; i < len(segments); i++ {
+		dirs = append(dirs, strings.Join(segments[:i], "/"))
+	}
+	return dirs
+}
+
+// String returns the rule in its original .ssyncignore syntax, useful for
+// logging which pattern matched.
+func (r rule) String() string {
+	s := r.pattern
+	if r.anchored {
+		s = "/" + s
+	}
+	if r.dirOnly {
+		s += "/"
+	}
+	if r.negate {
+		s = "!" + s
+	}
+	return s
+}
@@ -0,0 +1,69 @@
+package ignore
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRsyncFilterArgsReverseOrder(t *testing.T) {
+	m := loadMatcher(t, "*.log\n!debug.log\n")
+
+	got := m.RsyncFilterArgs()
+	want := []string{"--include", "debug.log", "--exclude", "*.log"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RsyncFilterArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestRsyncFilterArgsNilMatcher(t *testing.T) {
+	var m *Matcher
+	if got := m.RsyncFilterArgs(); got != nil {
+		t.Errorf("RsyncFilterArgs() = %v, want nil", got)
+	}
+}
+
+// TestRsyncFilterArgsNegationUnderDirExclude covers rsync's own documented
+// caveat: excluding a directory prunes it before a later --include for a
+// path underneath is ever reached, so re-including "node_modules/keep-me"
+// needs an explicit --include for "node_modules" itself to let rsync
+// descend that far in the first place, plus a trailing exclude so the rest
+// of node_modules stays excluded.
+func TestRsyncFilterArgsNegationUnderDirExclude(t *testing.T) {
+	m := loadMatcher(t, "node_modules/\n!node_modules/keep-me\n")
+
+	if excluded := m.Match("node_modules/keep-me", false); excluded {
+		t.Fatalf("precondition failed: Match should not exclude the negated path")
+	}
+
+	got := m.RsyncFilterArgs()
+	want := []string{
+		"--include", "/node_modules",
+		"--include", "/node_modules/keep-me",
+		"--exclude", "node_modules/",
+		"--exclude", "/node_modules/*",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RsyncFilterArgs() = %v, want %v", got, want)
+	}
+}
+
+// TestRsyncFilterArgsNegationUnderNestedDirExclude covers a negated path
+// two directories deep: both ancestors need their own --include so rsync
+// can descend all the way down to the negated file, and their own trailing
+// exclude so nothing else beneath them is transferred.
+func TestRsyncFilterArgsNegationUnderNestedDirExclude(t *testing.T) {
+	m := loadMatcher(t, "build/\n!build/dist/keep-me\n")
+
+	got := m.RsyncFilterArgs()
+	want := []string{
+		"--include", "/build",
+		"--include", "/build/dist",
+		"--include", "/build/dist/keep-me",
+		"--exclude", "build/",
+		"--exclude", "/build/*",
+		"--exclude", "/build/dist/*",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RsyncFilterArgs() = %v, want %v", got, want)
+	}
+}
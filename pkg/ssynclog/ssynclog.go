@@ -0,0 +1,101 @@
+// Package ssynclog provides the small leveled logger ssync uses for its
+// status output, optionally mirroring it to syslog so a long-running watcher
+// leaves a machine-readable audit trail behind.
+package ssynclog
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Level is a logging severity, ordered from most to least verbose.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// ParseLevel parses one of "debug", "info", "warn" or "error" (any case).
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return LevelDebug, nil
+	case "info":
+		return LevelInfo, nil
+	case "warn":
+		return LevelWarn, nil
+	case "error":
+		return LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown -log-level %q", s)
+	}
+}
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// Logger writes leveled status lines to stdout/stderr, honoring -quiet and
+// -log-level, and optionally mirrors info-and-above lines to syslog.
+type Logger struct {
+	level  Level
+	quiet  bool
+	syslog syslogWriter
+}
+
+// New creates a Logger that only emits lines at level or above, and that
+// stays silent on the console entirely when quiet is true.
+func New(level Level, quiet bool) *Logger {
+	return &Logger{level: level, quiet: quiet}
+}
+
+// EnableSyslog opens a connection to the local syslog daemon and mirrors
+// every info-and-above line there, regardless of -quiet. It is only
+// supported on Unix.
+func (l *Logger) EnableSyslog() error {
+	w, err := openSyslog()
+	if err != nil {
+		return err
+	}
+	l.syslog = w
+	return nil
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) { l.logf(LevelDebug, format, args...) }
+func (l *Logger) Infof(format string, args ...interface{})  { l.logf(LevelInfo, format, args...) }
+func (l *Logger) Warnf(format string, args ...interface{})  { l.logf(LevelWarn, format, args...) }
+func (l *Logger) Errorf(format string, args ...interface{}) { l.logf(LevelError, format, args...) }
+
+func (l *Logger) logf(level Level, format string, args ...interface{}) {
+	line := fmt.Sprintf(format, args...)
+
+	if l.syslog != nil && level >= LevelInfo {
+		l.syslog.writeLevel(level, line)
+	}
+
+	if l.quiet || level < l.level {
+		return
+	}
+
+	out := os.Stdout
+	if level == LevelError {
+		out = os.Stderr
+	}
+
+	fmt.Fprintf(out, "[%s] %s\n", level, line)
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+
+package ssynclog
+
+import "log/syslog"
+
+// syslogWriter abstracts the syslog connection so the cross-platform parts
+// of Logger don't need a build tag of their own.
+type syslogWriter interface {
+	writeLevel(level Level, line string)
+}
+
+type unixSyslog struct {
+	w *syslog.Writer
+}
+
+func (s *unixSyslog) writeLevel(level Level, line string) {
+	switch level {
+	case LevelWarn:
+		s.w.Warning(line)
+	case LevelError:
+		s.w.Err(line)
+	default:
+		s.w.Info(line)
+	}
+}
+
+func openSyslog() (syslogWriter, error) {
+	w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_USER, "ssync")
+	if err != nil {
+		return nil, err
+	}
+	return &unixSyslog{w: w}, nil
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package ssynclog
+
+import "errors"
+
+type syslogWriter interface {
+	writeLevel(level Level, line string)
+}
+
+func openSyslog() (syslogWriter, error) {
+	return nil, errors.New("-syslog is only supported on Unix")
+}
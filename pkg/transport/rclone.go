@@ -0,0 +1,71 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// rcloneTransport shells out to the rclone binary, so a destination can be
+// any remote rclone itself supports (S3, GCS, B2, ...). Endpoint.Path is
+// the rclone "remote:path" argument, unprefixed by ssync's own "rclone:"
+// endpoint syntax.
+type rcloneTransport struct{}
+
+// Capabilities reports what ssync's use of rclone actually exercises:
+// rclone has no notion of compression or partial-file resume for most
+// backends, and --delete maps onto choosing "sync" over "copy" below.
+func (t *rcloneTransport) Capabilities() Caps {
+	return Caps{SupportsDelete: true, SupportsCompress: false, SupportsPartialFile: false}
+}
+
+func (t *rcloneTransport) Sync(ctx context.Context, src, dst Endpoint, opts SyncOptions) (Result, error) {
+	// rclone sync mirrors the destination to the source, deleting extras;
+	// rclone copy never deletes. That's the closest match to ssync's own
+	// -delete flag that rclone's subcommands offer.
+	subcommand := "copy"
+	if opts.Delete {
+		subcommand = "sync"
+	}
+
+	args := []string{subcommand, src.Path, dst.Path}
+
+	if opts.Progress {
+		args = append(args, "--progress")
+	}
+	if opts.DryRun {
+		args = append(args, "--dry-run")
+	}
+
+	// rclone's filter syntax is close enough to rsync's include/exclude
+	// rules to reuse directly; a pattern that relies on gitignore-specific
+	// edge cases may not translate perfectly.
+	if opts.Matcher != nil {
+		args = append(args, opts.Matcher.RsyncFilterArgs()...)
+	}
+
+	cmd := exec.CommandContext(ctx, "rclone", args...)
+
+	var stderr bytes.Buffer
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+
+	runErr := cmd.Run()
+
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+
+	// rclone's default output isn't structured the way rsync's --stats is,
+	// so Stats is left zero-valued here; only the exit code and any error
+	// output are reported.
+	result := Result{ExitCode: exitCode}
+	if runErr != nil {
+		result.StderrTail = stderrTail(stderr.String())
+	}
+
+	return result, runErr
+}
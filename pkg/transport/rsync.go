@@ -0,0 +1,148 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// rsyncTransport shells out to the system rsync binary. It is the original
+// sync path from before transports were pluggable. Each Endpoint is turned
+// into an rsync argument by rsyncArg: a local path as is, or
+// "[user@]host:path" for a remote one.
+type rsyncTransport struct{}
+
+func (t *rsyncTransport) Capabilities() Caps {
+	return Caps{SupportsDelete: true, SupportsCompress: true, SupportsPartialFile: true}
+}
+
+func (t *rsyncTransport) Sync(ctx context.Context, src, dst Endpoint, opts SyncOptions) (Result, error) {
+	args := buildRsyncArgs(rsyncArg(src), rsyncArg(dst), opts)
+	args = append(args, "--stats")
+
+	cmd := exec.CommandContext(ctx, "rsync", args...)
+
+	command := "rsync " + strings.Join(args, " ")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = io.MultiWriter(os.Stdout, &stdout)
+	cmd.Stderr = io.MultiWriter(os.Stderr, &stderr)
+
+	runErr := cmd.Run()
+
+	exitCode := -1
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+
+	result := Result{
+		ExitCode: exitCode,
+		Stats:    parseRsyncStats(stdout.String()),
+		Command:  command,
+	}
+	if runErr != nil {
+		result.StderrTail = stderrTail(stderr.String())
+	}
+
+	return result, runErr
+}
+
+// rsyncArg formats an Endpoint the way the rsync binary expects it on its
+// command line: a bare path for a local endpoint, or "host:path" /
+// "user@host:path" for a remote one. Endpoint.Path never carries the host
+// prefix itself - newEndpoint fills Host/User/Path separately so every
+// transport can read them in its own dialect.
+func rsyncArg(e Endpoint) string {
+	if e.IsLocal || e.Host == "" {
+		return e.Path
+	}
+	host := e.Host
+	if e.User != "" {
+		host = e.User + "@" + host
+	}
+	return host + ":" + e.Path
+}
+
+// buildRsyncArgs assembles the rsync argument list for one sync, so the
+// command line a caller might log in dry-run mode is exactly the one that
+// would otherwise execute.
+func buildRsyncArgs(source, destination string, opts SyncOptions) []string {
+	rsyncArgs := []string{
+		"-a", // Archive mode (recursive), verbose, compress
+	}
+
+	if opts.Verbose {
+		rsyncArgs[0] += "v"
+	}
+
+	if opts.Compress {
+		rsyncArgs[0] += "z"
+	}
+
+	if opts.Progress {
+		rsyncArgs = append(rsyncArgs, "--progress")
+	}
+
+	if opts.Delete {
+		rsyncArgs = append(rsyncArgs, "--delete")
+	}
+
+	if opts.DryRun {
+		rsyncArgs = append(rsyncArgs, "--dry-run")
+	}
+
+	if opts.Matcher != nil {
+		rsyncArgs = append(rsyncArgs, opts.Matcher.RsyncFilterArgs()...)
+	}
+
+	rsyncArgs = append(rsyncArgs, source+"/", destination)
+
+	return rsyncArgs
+}
+
+var statsLinePattern = regexp.MustCompile(`^(Number of files transferred|Total bytes sent|Total bytes received):\s*([\d,]+)`)
+
+// parseRsyncStats extracts the handful of --stats fields ssync cares about
+// from rsync's stdout.
+func parseRsyncStats(output string) Stats {
+	var stats Stats
+
+	for _, line := range strings.Split(output, "\n") {
+		m := statsLinePattern.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+
+		value, err := strconv.ParseInt(strings.ReplaceAll(m[2], ",", ""), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		switch m[1] {
+		case "Number of files transferred":
+			stats.FilesTransferred = int(value)
+		case "Total bytes sent":
+			stats.BytesSent = value
+		case "Total bytes received":
+			stats.BytesReceived = value
+		}
+	}
+
+	return stats
+}
+
+// stderrTail returns the last few lines of output, so a failed-sync result
+// doesn't grow unbounded on a noisy failure.
+func stderrTail(output string) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	const maxLines = 10
+	if len(lines) > maxLines {
+		lines = lines[len(lines)-maxLines:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,78 @@
+package transport
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/alexellis/ssync/pkg/ignore"
+)
+
+func TestBuildRsyncArgs(t *testing.T) {
+	cases := []struct {
+		name string
+		opts SyncOptions
+		want []string
+	}{
+		{
+			name: "defaults",
+			opts: SyncOptions{},
+			want: []string{"-a", "src/", "dst"},
+		},
+		{
+			name: "verbose and compress fold into the archive flag",
+			opts: SyncOptions{Verbose: true, Compress: true},
+			want: []string{"-avz", "src/", "dst"},
+		},
+		{
+			name: "progress, delete and dry-run each add their own flag",
+			opts: SyncOptions{Progress: true, Delete: true, DryRun: true},
+			want: []string{"-a", "--progress", "--delete", "--dry-run", "src/", "dst"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := buildRsyncArgs("src", "dst", tc.opts)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("buildRsyncArgs() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBuildRsyncArgsAppendsMatcherFilters(t *testing.T) {
+	fsys := fstest.MapFS{".ssyncignore": {Data: []byte("*.log\n")}}
+	matcher, err := ignore.Load(fsys, ".ssyncignore")
+	if err != nil {
+		t.Fatalf("ignore.Load: %v", err)
+	}
+
+	got := buildRsyncArgs("src", "dst", SyncOptions{Matcher: matcher})
+	want := []string{"-a", "--exclude", "*.log", "src/", "dst"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("buildRsyncArgs() = %v, want %v", got, want)
+	}
+}
+
+func TestParseRsyncStats(t *testing.T) {
+	output := `Number of files: 12
+Number of files transferred: 3
+Total file size: 4,096 bytes
+Total bytes sent: 1,234
+Total bytes received: 567
+`
+
+	got := parseRsyncStats(output)
+	want := Stats{FilesTransferred: 3, BytesSent: 1234, BytesReceived: 567}
+	if got != want {
+		t.Errorf("parseRsyncStats() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseRsyncStatsIgnoresUnrecognisedLines(t *testing.T) {
+	got := parseRsyncStats("sent 120 bytes  received 35 bytes\ntotal size is 4,096\n")
+	if got != (Stats{}) {
+		t.Errorf("parseRsyncStats() = %+v, want zero value", got)
+	}
+}
@@ -0,0 +1,160 @@
+package transport
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/pkg/sftp"
+
+	"github.com/alexellis/ssync/pkg/ignore"
+)
+
+// sftpClientServerPair starts an in-process SFTP server rooted at the real
+// filesystem (same as a real sshd would be) and connects a client to it
+// over an in-memory pipe, so planSFTPSync can be exercised without a
+// network round trip or a live sshd.
+func sftpClientServerPair(t *testing.T) *sftp.Client {
+	t.Helper()
+
+	clientRead, serverWrite := io.Pipe()
+	serverRead, clientWrite := io.Pipe()
+
+	server, err := sftp.NewServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{serverRead, serverWrite})
+	if err != nil {
+		t.Fatalf("sftp.NewServer: %v", err)
+	}
+	go server.Serve()
+
+	client, err := sftp.NewClientPipe(clientRead, clientWrite)
+	if err != nil {
+		t.Fatalf("sftp.NewClientPipe: %v", err)
+	}
+	// Close the server first: Client.Close waits for its receive loop to
+	// exit, which only happens once the server side of the pipe closes too.
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+
+	return client
+}
+
+func writeFile(t *testing.T, path string, contents string, mtime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+}
+
+func TestPlanSFTPSyncUpload(t *testing.T) {
+	client := sftpClientServerPair(t)
+
+	localRoot := t.TempDir()
+	remoteRoot := t.TempDir()
+
+	now := time.Now().Truncate(time.Second)
+	older := now.Add(-time.Hour)
+
+	writeFile(t, filepath.Join(localRoot, "unchanged.txt"), "same", now)
+	writeFile(t, filepath.Join(remoteRoot, "unchanged.txt"), "same", now)
+
+	writeFile(t, filepath.Join(localRoot, "newer.txt"), "local version", now)
+	writeFile(t, filepath.Join(remoteRoot, "newer.txt"), "stale version", older)
+
+	writeFile(t, filepath.Join(localRoot, "added.txt"), "new", now)
+
+	writeFile(t, filepath.Join(localRoot, "ignored.log"), "skip me", now)
+
+	fsys := fstest.MapFS{".ssyncignore": {Data: []byte("*.log\n")}}
+	matcher, err := ignore.Load(fsys, ".ssyncignore")
+	if err != nil {
+		t.Fatalf("ignore.Load: %v", err)
+	}
+
+	jobs, err := planSFTPSync(client, localRoot, remoteRoot, true, matcher)
+	if err != nil {
+		t.Fatalf("planSFTPSync: %v", err)
+	}
+
+	got := map[string]bool{}
+	for _, j := range jobs {
+		rel, err := filepath.Rel(localRoot, j.localPath)
+		if err != nil {
+			t.Fatalf("Rel: %v", err)
+		}
+		got[filepath.ToSlash(rel)] = true
+	}
+
+	want := map[string]bool{"newer.txt": true, "added.txt": true}
+	if len(got) != len(want) {
+		t.Fatalf("planSFTPSync jobs = %v, want %v", got, want)
+	}
+	for name := range want {
+		if !got[name] {
+			t.Errorf("planSFTPSync jobs missing %q, got %v", name, got)
+		}
+	}
+}
+
+func TestPlanSFTPSyncDownload(t *testing.T) {
+	client := sftpClientServerPair(t)
+
+	localRoot := t.TempDir()
+	remoteRoot := t.TempDir()
+
+	now := time.Now().Truncate(time.Second)
+	older := now.Add(-time.Hour)
+
+	writeFile(t, filepath.Join(remoteRoot, "unchanged.txt"), "same", now)
+	writeFile(t, filepath.Join(localRoot, "unchanged.txt"), "same", now)
+
+	writeFile(t, filepath.Join(remoteRoot, "newer.txt"), "remote version", now)
+	writeFile(t, filepath.Join(localRoot, "newer.txt"), "stale version", older)
+
+	writeFile(t, filepath.Join(remoteRoot, "added.txt"), "new", now)
+
+	writeFile(t, filepath.Join(remoteRoot, "ignored.log"), "skip me", now)
+
+	fsys := fstest.MapFS{".ssyncignore": {Data: []byte("*.log\n")}}
+	matcher, err := ignore.Load(fsys, ".ssyncignore")
+	if err != nil {
+		t.Fatalf("ignore.Load: %v", err)
+	}
+
+	jobs, err := planSFTPSync(client, localRoot, remoteRoot, false, matcher)
+	if err != nil {
+		t.Fatalf("planSFTPSync: %v", err)
+	}
+
+	got := map[string]bool{}
+	for _, j := range jobs {
+		rel, err := filepath.Rel(remoteRoot, j.remotePath)
+		if err != nil {
+			t.Fatalf("Rel: %v", err)
+		}
+		got[filepath.ToSlash(rel)] = true
+	}
+
+	want := map[string]bool{"newer.txt": true, "added.txt": true}
+	if len(got) != len(want) {
+		t.Fatalf("planSFTPSync jobs = %v, want %v", got, want)
+	}
+	for name := range want {
+		if !got[name] {
+			t.Errorf("planSFTPSync jobs missing %q, got %v", name, got)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Package transport abstracts the mechanism ssync uses to move files from
+// one Endpoint to another, so the watcher loop can stay agnostic to whether
+// a sync happens over rsync, sftp or rclone.
+package transport
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alexellis/ssync/pkg/ignore"
+)
+
+// Endpoint describes one side of a sync. For a local endpoint Path is a
+// filesystem path. For a remote ssh or sftp endpoint Path is the remote
+// directory alone (e.g. "~/project"), with Host, User and Port held
+// separately; the rsync transport joins them into "user@host:path". For
+// rclone, Path is the rclone "remote:path" argument.
+type Endpoint struct {
+	Transport string // "", "rsync", "sftp" or "rclone"; "" defers to the caller's default
+	Host      string
+	User      string
+	Port      int
+	Path      string
+	IsLocal   bool
+}
+
+// SyncOptions carries the flags common to every transport. Not every
+// transport honours every field - see Capabilities.
+type SyncOptions struct {
+	Compress bool
+	Verbose  bool
+	Progress bool
+	Delete   bool
+	DryRun   bool
+	Matcher  *ignore.Matcher
+}
+
+// Stats is the subset of transfer accounting ssync surfaces on /status.
+// Transports populate it on a best-effort basis.
+type Stats struct {
+	FilesTransferred int   `json:"files_transferred"`
+	BytesSent        int64 `json:"bytes_sent"`
+	BytesReceived    int64 `json:"bytes_received"`
+}
+
+// Result is the structured outcome of one Sync call.
+type Result struct {
+	ExitCode   int    `json:"exit_code"`
+	StderrTail string `json:"stderr_tail,omitempty"`
+	Stats      Stats  `json:"stats"`
+	// Command is the command line the transport ran, populated on a
+	// best-effort basis so a -dry-run caller can show what would otherwise
+	// have executed. Not every transport sets it.
+	Command string `json:"command,omitempty"`
+}
+
+// Caps describes what a transport implementation supports, so callers can
+// warn up front rather than failing mid-sync.
+type Caps struct {
+	SupportsDelete      bool
+	SupportsCompress    bool
+	SupportsPartialFile bool
+}
+
+// Transport performs one-way synchronisation from src to dst.
+type Transport interface {
+	Sync(ctx context.Context, src, dst Endpoint, opts SyncOptions) (Result, error)
+	Capabilities() Caps
+}
+
+// New returns the Transport registered under name. An empty name selects
+// rsync, the transport ssync used exclusively before transports were
+// pluggable.
+func New(name string) (Transport, error) {
+	switch name {
+	case "", "rsync":
+		return &rsyncTransport{}, nil
+	case "sftp":
+		return newSFTPTransport(), nil
+	case "rclone":
+		return &rcloneTransport{}, nil
+	default:
+		return nil, fmt.Errorf("unknown transport %q (want rsync, sftp or rclone)", name)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/alexellis/ssync/pkg/ssynclog"
+	"github.com/alexellis/ssync/pkg/transport"
+)
+
+// maxTrackedErrors bounds the /errors ring buffer so a host that's been
+// failing for a long time doesn't grow the process's memory without limit.
+const maxTrackedErrors = 50
+
+// StatusError is one entry in the /errors ring buffer.
+type StatusError struct {
+	Time    time.Time `json:"time"`
+	Path    string    `json:"path,omitempty"`
+	Message string    `json:"message"`
+}
+
+// Status is shared, mutex-guarded sync state, populated by the watcher and
+// read by the HTTP status handlers.
+type Status struct {
+	mu sync.Mutex
+
+	lastSyncStart time.Time
+	lastSyncEnd   time.Time
+	lastDuration  time.Duration
+	lastResult    transport.Result
+
+	debouncePending bool
+	debounceFiresAt time.Time
+	pendingPaths    int
+
+	errors []StatusError
+
+	broadcaster *eventBroadcaster
+}
+
+// NewStatus creates an empty Status ready to be shared between the watcher
+// and the HTTP status server.
+func NewStatus() *Status {
+	return &Status{broadcaster: newEventBroadcaster()}
+}
+
+// RecordPathChanged notes that path changed and a sync has been debounced to
+// fire at firesAt, for the /status endpoint's debounce timer state.
+func (s *Status) RecordPathChanged(path string, firesAt time.Time) {
+	s.mu.Lock()
+	s.pendingPaths++
+	s.debouncePending = true
+	s.debounceFiresAt = firesAt
+	s.mu.Unlock()
+
+	s.broadcaster.publish("path-changed", path)
+}
+
+// RecordSyncStart marks a sync as having begun, clearing the debounce and
+// pending-path state it was tracking.
+func (s *Status) RecordSyncStart() {
+	s.mu.Lock()
+	s.lastSyncStart = timeNow()
+	s.debouncePending = false
+	s.pendingPaths = 0
+	s.mu.Unlock()
+
+	s.broadcaster.publish("sync-start", "")
+}
+
+// RecordSyncResult stores the outcome of a finished sync and, if it failed,
+// appends it to the /errors ring buffer.
+func (s *Status) RecordSyncResult(result transport.Result, err error) {
+	s.mu.Lock()
+	s.lastSyncEnd = timeNow()
+	s.lastDuration = s.lastSyncEnd.Sub(s.lastSyncStart)
+	s.lastResult = result
+	s.mu.Unlock()
+
+	if err != nil {
+		s.RecordError("", fmt.Sprintf("sync exited %d: %v", result.ExitCode, err))
+	}
+
+	s.broadcaster.publish("sync-end", fmt.Sprintf("exit=%d", result.ExitCode))
+}
+
+// RecordError appends an entry to the rolling /errors buffer, used for both
+// rsync failures and fsnotify watcher errors.
+func (s *Status) RecordError(path, message string) {
+	s.mu.Lock()
+	s.errors = append(s.errors, StatusError{Time: timeNow(), Path: path, Message: message})
+	if len(s.errors) > maxTrackedErrors {
+		s.errors = s.errors[len(s.errors)-maxTrackedErrors:]
+	}
+	s.mu.Unlock()
+
+	s.broadcaster.publish("error", message)
+}
+
+// statusSnapshot is the JSON body served from /status.
+type statusSnapshot struct {
+	LastSyncStart   *time.Time       `json:"last_sync_start,omitempty"`
+	LastSyncEnd     *time.Time       `json:"last_sync_end,omitempty"`
+	LastDurationMs  int64            `json:"last_duration_ms"`
+	LastResult      transport.Result `json:"last_result"`
+	DebouncePending bool             `json:"debounce_pending"`
+	DebounceFiresAt *time.Time       `json:"debounce_fires_at,omitempty"`
+	PendingPaths    int              `json:"pending_paths"`
+}
+
+func (s *Status) snapshot() statusSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := statusSnapshot{
+		LastDurationMs:  s.lastDuration.Milliseconds(),
+		LastResult:      s.lastResult,
+		DebouncePending: s.debouncePending,
+		PendingPaths:    s.pendingPaths,
+	}
+
+	if !s.lastSyncStart.IsZero() {
+		snap.LastSyncStart = &s.lastSyncStart
+	}
+	if !s.lastSyncEnd.IsZero() {
+		snap.LastSyncEnd = &s.lastSyncEnd
+	}
+	if s.debouncePending {
+		snap.DebounceFiresAt = &s.debounceFiresAt
+	}
+
+	return snap
+}
+
+func (s *Status) recentErrors() []StatusError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	errs := make([]StatusError, len(s.errors))
+	copy(errs, s.errors)
+	return errs
+}
+
+// timeNow exists so the few call sites above read as intent ("record the
+// time now") rather than a bare time.Now() sprinkled through the file.
+func timeNow() time.Time { return time.Now() }
+
+// eventBroadcaster fans a stream of lifecycle events out to every connected
+// /events subscriber.
+type eventBroadcaster struct {
+	mu          sync.Mutex
+	subscribers map[chan string]struct{}
+}
+
+func newEventBroadcaster() *eventBroadcaster {
+	return &eventBroadcaster{subscribers: map[chan string]struct{}{}}
+}
+
+func (b *eventBroadcaster) subscribe() chan string {
+	ch := make(chan string, 16)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *eventBroadcaster) unsubscribe(ch chan string) {
+	b.mu.Lock()
+	delete(b.subscribers, ch)
+	b.mu.Unlock()
+	close(ch)
+}
+
+func (b *eventBroadcaster) publish(kind, detail string) {
+	line := fmt.Sprintf("%s %s", kind, detail)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- line:
+		default:
+			// Slow subscriber - drop the event rather than block the sync loop.
+		}
+	}
+}
+
+// startStatusServer starts the -listen HTTP server in the background,
+// exposing /healthz, /status, /errors and /events. It returns once the
+// listener is ready; errors after that point (including Serve itself
+// returning) are logged rather than fatal, since the sync loop
+// should keep running even if the status server falls over.
+func startStatusServer(addr string, status *Status, logger *ssynclog.Logger) error {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+	})
+
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status.snapshot())
+	})
+
+	mux.HandleFunc("/errors", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status.recentErrors())
+	})
+
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		ch := status.broadcaster.subscribe()
+		defer status.broadcaster.unsubscribe(ch)
+
+		for {
+			select {
+			case line := <-ch:
+				fmt.Fprintf(w, "data: %s\n\n", line)
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("unable to start status server: %w", err)
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("status server stopped: %v", err)
+		}
+	}()
+
+	logger.Infof("Status server listening on %s", addr)
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alexellis/ssync/pkg/transport"
+)
+
+func TestStatusSnapshot(t *testing.T) {
+	s := NewStatus()
+
+	snap := s.snapshot()
+	if snap.LastSyncStart != nil || snap.LastSyncEnd != nil || snap.DebounceFiresAt != nil {
+		t.Fatalf("snapshot on a fresh Status should have no timestamps: %+v", snap)
+	}
+
+	s.RecordPathChanged("file.txt", timeNow())
+	if snap := s.snapshot(); !snap.DebouncePending || snap.PendingPaths != 1 || snap.DebounceFiresAt == nil {
+		t.Errorf("snapshot after RecordPathChanged = %+v, want debounce pending with 1 path", snap)
+	}
+
+	s.RecordSyncStart()
+	snap = s.snapshot()
+	if snap.DebouncePending || snap.PendingPaths != 0 || snap.LastSyncStart == nil {
+		t.Errorf("snapshot after RecordSyncStart = %+v, want debounce cleared and a start time", snap)
+	}
+
+	result := transport.Result{ExitCode: 0}
+	s.RecordSyncResult(result, nil)
+	snap = s.snapshot()
+	if snap.LastSyncEnd == nil || snap.LastResult != result {
+		t.Errorf("snapshot after RecordSyncResult = %+v, want last result recorded", snap)
+	}
+}
+
+func TestStatusRecordSyncResultErrorAppendsToErrors(t *testing.T) {
+	s := NewStatus()
+
+	s.RecordSyncResult(transport.Result{ExitCode: 23}, errors.New("connection reset"))
+
+	errs := s.recentErrors()
+	if len(errs) != 1 {
+		t.Fatalf("recentErrors() = %v, want 1 entry", errs)
+	}
+	if want := "sync exited 23: connection reset"; errs[0].Message != want {
+		t.Errorf("recentErrors()[0].Message = %q, want %q", errs[0].Message, want)
+	}
+}
+
+func TestStatusRecentErrorsCapsAtMaxTracked(t *testing.T) {
+	s := NewStatus()
+
+	for i := 0; i < maxTrackedErrors+10; i++ {
+		s.RecordError("", "boom")
+	}
+
+	errs := s.recentErrors()
+	if len(errs) != maxTrackedErrors {
+		t.Fatalf("recentErrors() len = %d, want %d", len(errs), maxTrackedErrors)
+	}
+}
+
+func TestEventBroadcasterPublishAndUnsubscribe(t *testing.T) {
+	b := newEventBroadcaster()
+
+	ch := b.subscribe()
+	b.publish("sync-start", "")
+
+	select {
+	case line := <-ch:
+		if line != "sync-start " {
+			t.Errorf("published line = %q, want %q", line, "sync-start ")
+		}
+	default:
+		t.Fatal("subscriber did not receive the published event")
+	}
+
+	b.unsubscribe(ch)
+	if _, ok := <-ch; ok {
+		t.Error("unsubscribe should close the channel")
+	}
+}
+
+func TestEventBroadcasterDropsOnSlowSubscriber(t *testing.T) {
+	b := newEventBroadcaster()
+	b.subscribe()
+
+	// The subscriber channel is bounded and never drained here; publish
+	// must drop events rather than block the sync loop on a slow reader.
+	// A blocking implementation would hang this test until the package's
+	// overall test timeout fires.
+	for i := 0; i < 32; i++ {
+		b.publish("path-changed", "file.txt")
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/alexellis/ssync/pkg/ignore"
+	"github.com/alexellis/ssync/pkg/ssynclog"
+)
+
+func TestWatchedDirsAddHas(t *testing.T) {
+	w := &watchedDirs{dirs: map[string]struct{}{}}
+
+	if w.has("/a") {
+		t.Fatalf("has(%q) = true before add", "/a")
+	}
+
+	w.add("/a")
+	if !w.has("/a") {
+		t.Errorf("has(%q) = false after add", "/a")
+	}
+}
+
+func TestWatchedDirsRemoveTree(t *testing.T) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatalf("fsnotify.NewWatcher: %v", err)
+	}
+	defer watcher.Close()
+
+	root := t.TempDir()
+	sub := filepath.Join(root, "sub")
+	sibling := filepath.Join(root, "sibling")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.MkdirAll(sibling, 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+
+	for _, dir := range []string{root, sub, sibling} {
+		if err := watcher.Add(dir); err != nil {
+			t.Fatalf("watcher.Add(%q): %v", dir, err)
+		}
+	}
+
+	w := &watchedDirs{dirs: map[string]struct{}{root: {}, sub: {}, sibling: {}}}
+	w.removeTree(watcher, sub)
+
+	if w.has(sub) {
+		t.Errorf("removeTree(%q) left %q watched", sub, sub)
+	}
+	if !w.has(root) || !w.has(sibling) {
+		t.Errorf("removeTree(%q) removed unrelated dirs, dirs = %v", sub, w.dirs)
+	}
+}
+
+func TestAddTreeToWatcherSkipsExcludedDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"keep", "node_modules", "node_modules/pkg"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatalf("MkdirAll: %v", err)
+		}
+	}
+
+	fsys := fstest.MapFS{".ssyncignore": {Data: []byte("node_modules/\n")}}
+	matcher, err := ignore.Load(fsys, ".ssyncignore")
+	if err != nil {
+		t.Fatalf("ignore.Load: %v", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatalf("fsnotify.NewWatcher: %v", err)
+	}
+	defer watcher.Close()
+
+	watched := &watchedDirs{dirs: map[string]struct{}{}}
+	logger := ssynclog.New(ssynclog.LevelError, true)
+
+	if err := addTreeToWatcher(watcher, watched, root, root, matcher, logger); err != nil {
+		t.Fatalf("addTreeToWatcher: %v", err)
+	}
+
+	if !watched.has(root) || !watched.has(filepath.Join(root, "keep")) {
+		t.Errorf("addTreeToWatcher did not watch non-excluded dirs, dirs = %v", watched.dirs)
+	}
+	if watched.has(filepath.Join(root, "node_modules")) || watched.has(filepath.Join(root, "node_modules", "pkg")) {
+		t.Errorf("addTreeToWatcher watched an excluded tree, dirs = %v", watched.dirs)
+	}
+}
+
+func TestAddTreeToWatcherSkipsAlreadyWatched(t *testing.T) {
+	root := t.TempDir()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatalf("fsnotify.NewWatcher: %v", err)
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(root); err != nil {
+		t.Fatalf("watcher.Add: %v", err)
+	}
+	watched := &watchedDirs{mu: sync.Mutex{}, dirs: map[string]struct{}{root: {}}}
+	logger := ssynclog.New(ssynclog.LevelError, true)
+
+	if err := addTreeToWatcher(watcher, watched, root, root, nil, logger); err != nil {
+		t.Fatalf("addTreeToWatcher: %v", err)
+	}
+
+	if len(watched.dirs) != 1 {
+		t.Errorf("addTreeToWatcher re-added an already-watched dir, dirs = %v", watched.dirs)
+	}
+}